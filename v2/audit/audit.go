@@ -0,0 +1,169 @@
+// Package audit records every command with its outcome, including the failed, denied and timed out ones
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	mjcontext "github.com/mirror-media/major-tom-go/v2/internal/context"
+)
+
+// Outcomes of the commands
+const (
+	OutcomeOK      = "ok"
+	OutcomeError   = "error"
+	OutcomeDenied  = "denied"
+	OutcomePending = "pending"
+	OutcomeTimeout = "timeout"
+)
+
+// Entry is the record of a command
+type Entry struct {
+	Time      time.Time `json:"time"`
+	UserID    string    `json:"userID"`
+	User      string    `json:"user"`
+	ChannelID string    `json:"channelID"`
+	// Text is the raw text of the command
+	Text    string   `json:"text"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Target is the service or the repo in the command
+	Target string `json:"target,omitempty"`
+	Repo   string `json:"repo,omitempty"`
+	Stage  string `json:"stage,omitempty"`
+	// Paths are the files in kubernetes-configs resolved for the command
+	Paths []string `json:"paths,omitempty"`
+	// Changes describe the old and new values
+	Changes    []string `json:"changes,omitempty"`
+	Commit     string   `json:"commit,omitempty"`
+	DurationMS int64    `json:"durationMs"`
+	Outcome    string   `json:"outcome"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Sink stores the entries
+type Sink interface {
+	Write(e Entry) error
+}
+
+// Querier finds the entries in a sink
+type Querier interface {
+	// Query returns the latest entries matching the query, latest first
+	Query(q Query) ([]Entry, error)
+}
+
+// Query matches the entries by the service or the repo, and by the user name or ID. An empty field matches all
+type Query struct {
+	Service string
+	User    string
+	Limit   int
+}
+
+func (q Query) matches(e Entry) bool {
+	if q.Service != "" && q.Service != e.Target && q.Service != e.Repo {
+		return false
+	}
+	if q.User != "" && q.User != e.User && q.User != e.UserID {
+		return false
+	}
+	return true
+}
+
+// Trace collects what a command did while it runs, in the deploy worker as well. The methods of a nil trace do nothing
+type Trace struct {
+	locker  sync.Mutex
+	paths   []string
+	changes []string
+	commit  string
+	denied  bool
+}
+
+// WithTrace returns a context carrying the trace
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, mjcontext.AuditTrace, t)
+}
+
+// TraceFrom returns the trace in ctx, or nil if the command is not audited
+func TraceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(mjcontext.AuditTrace).(*Trace)
+	return t
+}
+
+// AddPaths adds the files resolved for the command
+func (t *Trace) AddPaths(paths ...string) {
+	if t == nil {
+		return
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	for _, path := range paths {
+		if !contains(t.paths, path) {
+			t.paths = append(t.paths, path)
+		}
+	}
+}
+
+// AddChanges adds the descriptions of the changes
+func (t *Trace) AddChanges(changes ...string) {
+	if t == nil {
+		return
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	t.changes = append(t.changes, changes...)
+}
+
+// SetCommit sets the hash of the commit pushed by the command
+func (t *Trace) SetCommit(hash string) {
+	if t == nil {
+		return
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	t.commit = hash
+}
+
+// Deny marks the command as denied by the policy
+func (t *Trace) Deny() {
+	if t == nil {
+		return
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	t.denied = true
+}
+
+// IsDenied reports whether the command was denied by the policy
+func (t *Trace) IsDenied() bool {
+	if t == nil {
+		return false
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	return t.denied
+}
+
+// Fill copies what the trace collected to the entry
+func (t *Trace) Fill(e *Entry) {
+	if t == nil {
+		return
+	}
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	e.Paths = append([]string(nil), t.paths...)
+	e.Changes = append([]string(nil), t.changes...)
+	e.Commit = t.commit
+	if t.denied {
+		e.Outcome = OutcomeDenied
+	}
+}
+
+func contains(s []string, target string) bool {
+	for _, v := range s {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
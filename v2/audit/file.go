@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxLineSize is the longest line of an entry read from the file
+const maxLineSize = 1024 * 1024
+
+// File is an append-only JSON lines file of the entries
+type File struct {
+	locker sync.Mutex
+	path   string
+}
+
+// NewFile returns the sink of the file, which is created if it doesn't exist
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("opening audit log(%s) has error", path))
+	}
+	return &File{path: path}, f.Close()
+}
+
+// Write appends the entry as a line. The file is opened for each entry so it can be rotated
+func (f *File) Write(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("opening audit log(%s) has error", f.path))
+	}
+	_, err = file.Write(append(b, '\n'))
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, fmt.Sprintf("writing audit log(%s) has error", f.path))
+	}
+	return file.Close()
+}
+
+// Query scans the file for the latest entries matching the query. Lines which are not entries are skipped
+func (f *File) Query(q Query) ([]Entry, error) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("opening audit log(%s) has error", f.path))
+	}
+	defer file.Close()
+
+	var matched []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if !q.matches(e) {
+			continue
+		}
+		matched = append(matched, e)
+		// only the latest ones are kept
+		if q.Limit > 0 && len(matched) > q.Limit {
+			matched = matched[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("reading audit log(%s) has error", f.path))
+	}
+
+	latest := make([]Entry, len(matched))
+	for i, e := range matched {
+		latest[len(matched)-1-i] = e
+	}
+	return latest, nil
+}
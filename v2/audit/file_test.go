@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFile_Query(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{User: "alice", UserID: "U1", Command: "deploy", Target: "openwarehouse", Repo: "openwarehouse", Outcome: OutcomeOK},
+		{User: "bob", UserID: "U2", Command: "scale", Target: "openwarehouse-tv-cms", Repo: "openwarehouse", Outcome: OutcomeDenied},
+		{User: "alice", UserID: "U1", Command: "release", Target: "mirror-tv-nuxt", Repo: "mirror-tv-nuxt", Outcome: OutcomeError},
+		{User: "alice", UserID: "U1", Command: "info", Target: "openwarehouse-tv-cms", Repo: "openwarehouse", Outcome: OutcomeTimeout},
+	} {
+		e.Time = start.Add(time.Duration(i) * time.Minute)
+		if err := f.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// lines which are not entries are skipped
+	b, _ := ioutil.ReadFile(path)
+	if err := ioutil.WriteFile(path, append(b, []byte("not an entry\n")...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		query        Query
+		wantCommands []string
+	}{
+		{
+			name:         "all latest first",
+			query:        Query{},
+			wantCommands: []string{"info", "release", "scale", "deploy"},
+		},
+		{
+			name:         "by user",
+			query:        Query{User: "alice", Limit: 2},
+			wantCommands: []string{"info", "release"},
+		},
+		{
+			name:         "by user ID",
+			query:        Query{User: "U2"},
+			wantCommands: []string{"scale"},
+		},
+		{
+			name:         "by service",
+			query:        Query{Service: "openwarehouse-tv-cms"},
+			wantCommands: []string{"info", "scale"},
+		},
+		{
+			name:         "by repo",
+			query:        Query{Service: "openwarehouse"},
+			wantCommands: []string{"info", "scale", "deploy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := f.Query(tt.query)
+			if err != nil {
+				t.Fatalf("File.Query() error = %v", err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Command)
+			}
+			if !reflect.DeepEqual(got, tt.wantCommands) {
+				t.Errorf("File.Query() = %v, want %v", got, tt.wantCommands)
+			}
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var trace *Trace
+	// a command without trace is not audited
+	trace.AddPaths("openwarehouse/overlays/dev/base/kustomization.yaml")
+
+	trace = &Trace{}
+	trace.AddPaths("a.yaml", "b.yaml")
+	trace.AddPaths("a.yaml")
+	trace.AddChanges("Set image-tag(images.0.newTag) of openwarehouse from dev_1 to dev_2")
+	trace.SetCommit("1111111111111111111111111111111111111111")
+	trace.Deny()
+
+	e := Entry{Outcome: OutcomeError}
+	trace.Fill(&e)
+	if !reflect.DeepEqual(e.Paths, []string{"a.yaml", "b.yaml"}) {
+		t.Errorf("Entry.Paths = %v", e.Paths)
+	}
+	if len(e.Changes) != 1 || e.Commit != "1111111111111111111111111111111111111111" || e.Outcome != OutcomeDenied {
+		t.Errorf("Trace.Fill() = %+v", e)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -11,7 +12,14 @@ import (
 	formatter "github.com/bcgodev/logrus-formatter-gke"
 	gookitconfig "github.com/gookit/config/v2"
 	"github.com/gookit/config/v2/yaml"
+	"github.com/mirror-media/major-tom-go/v2/audit"
+	"github.com/mirror-media/major-tom-go/v2/command"
 	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/freeze"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"github.com/mirror-media/major-tom-go/v2/metrics"
+	"github.com/mirror-media/major-tom-go/v2/pending"
+	"github.com/mirror-media/major-tom-go/v2/registry"
 	"github.com/mirror-media/major-tom-go/v2/slashcommand"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -72,9 +80,83 @@ func main() {
 		logrus.Panic(fmt.Errorf("fatal error binding config file to struct: %s", err))
 	}
 
+	err = cfg.Policy.Validate()
+	if err != nil {
+		logrus.Panic(err)
+	}
+	err = k8sRepoCFG.ValidateTagPolicies()
+	if err != nil {
+		logrus.Panic(err)
+	}
+
+	freezes, err := freeze.NewStore(cfg.Freeze)
+	if err != nil {
+		logrus.Panic(errors.Wrap(err, "loading freezes has error"))
+	}
+	command.DeployWorker.SetFreezes(freezes)
+
+	// commands are not recorded without an audit log
+	var auditSink audit.Sink
+	if cfg.Audit.File != "" {
+		auditSink, err = audit.NewFile(cfg.Audit.File)
+		if err != nil {
+			logrus.Panic(err)
+		}
+	}
+
+	registryClient, err := registry.NewClient(cfg.Registry)
+	if err != nil {
+		logrus.Panic(errors.Wrap(err, "creating registry client has error"))
+	}
+	command.DeployWorker.SetRegistry(registryClient)
+
+	rolloutTimeout, err := cfg.Rollout.GetTimeout()
+	if err != nil {
+		logrus.Panic(err)
+	}
+	command.DeployWorker.SetRolloutTimeout(rolloutTimeout)
+
+	err = metrics.RegisterDeployQueue(command.QueueDepth)
+	if err != nil {
+		logrus.Panic(errors.Wrap(err, "registering deploy queue metric has error"))
+	}
+	go func() {
+		addr := cfg.Metrics.GetAddr()
+		logrus.Infof("serving metrics and health checks on %s", addr)
+		err := http.ListenAndServe(addr, newServeMux(command.DeployWorker.IsReady))
+		logrus.Panic(errors.Wrap(err, "serving metrics has error"))
+	}()
+	// kubernetes-configs is cloned before connecting to Slack so the bot is ready when the commands arrive
+	command.DeployWorker.Set(k8sRepoCFG.Git)
+
+	clusters, err := k8sop.NewClusterResolver(cfg.Clusters)
+	if err != nil {
+		logrus.Panic(errors.Wrap(err, "loading clusters has error"))
+	}
+	for _, codebase := range k8sRepoCFG.Configs {
+		for _, stage := range codebase.Stages {
+			for _, project := range codebase.Projects {
+				if _, err := cfg.Clusters.Get(codebase.Repo, project, stage); err != nil {
+					logrus.Warn(err)
+				}
+			}
+		}
+	}
+
+	confirmationTimeout, err := cfg.Confirmation.GetTimeout()
+	if err != nil {
+		logrus.Panic(err)
+	}
+	approvers, err := cfg.Confirmation.GetApprovers(cfg.Policy)
+	if err != nil {
+		logrus.Panic(err)
+	}
+	requests := pending.NewStore(confirmationTimeout, approvers, cfg.Confirmation.TwoPerson)
+
 	appToken := cfg.SlackAppToken
 
-	api := slack.New("",
+	// the bot token is only required to post the follow-ups in threads
+	api := slack.New(cfg.SlackBotToken,
 		slack.OptionDebug(true),
 		slack.OptionAppLevelToken(appToken))
 
@@ -86,8 +168,9 @@ func main() {
 
 	ctx := context.Background()
 
-	// TODO
-	// clusterConfigs := cfg.ClusterConfigs
+	// hasConnected tells the reconnections from the first connection
+	var hasConnected bool
+
 	go func() {
 		for evt := range client.Events {
 			select {
@@ -102,6 +185,10 @@ func main() {
 					logrus.Info("Connection failed. Retrying later...")
 				case socketmode.EventTypeConnected:
 					logrus.Info("Connected to Slack with Socket Mode.")
+					if hasConnected {
+						metrics.SlackReconnects.Inc()
+					}
+					hasConnected = true
 				case socketmode.EventTypeEventsAPI:
 					eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
 					if !ok {
@@ -139,11 +226,16 @@ func main() {
 
 					var payload interface{}
 
+					// answering a confirmation may take longer than slack waits for the ack
+					client.Ack(*evt.Request, payload)
+
 					switch callback.Type {
 					case slack.InteractionTypeBlockActions:
 						// See https://api.slack.com/apis/connections/socket-implement#button
-
-						client.Debugf("button clicked!")
+						// an approved change waits for the deploy worker, which must not hold up the other events
+						for _, action := range callback.ActionCallback.BlockActions {
+							go answerRequest(ctx, api, requests, cfg.Policy, auditSink, callback, action)
+						}
 					case slack.InteractionTypeShortcut:
 					case slack.InteractionTypeViewSubmission:
 						// See https://api.slack.com/apis/connections/socket-implement#modal
@@ -151,8 +243,6 @@ func main() {
 					default:
 
 					}
-
-					client.Ack(*evt.Request, payload)
 				case socketmode.EventTypeSlashCommand:
 					cmd, ok := evt.Data.(slack.SlashCommand)
 					if !ok {
@@ -168,17 +258,8 @@ func main() {
 
 					client.Ack(*evt.Request, payload)
 
-					messages, err := slashcommand.Run(ctx, k8sRepoCFG, cmd.Command, cmd.Text, cmd.UserName)
-					if messages == nil {
-						messages = []string{}
-					}
-					if err != nil {
-						messages = append([]string{err.Error()}, messages...)
-					}
-
-					message := fmt.Sprintf("<@%s> on ground control\n```%s```", cmd.UserID, strings.Join(messages, "\n"))
-
-					api.PostMessage(cmd.ChannelID, slack.MsgOptionResponseURL(cmd.ResponseURL, "in_channel"), slack.MsgOptionText(message, false))
+					// a command may wait for the deploy worker or for kubernetes-configs, which must not hold up the other events
+					go runCommand(ctx, api, clusters, requests, cfg, auditSink, k8sRepoCFG, cmd)
 
 				default:
 					logrus.Errorf("Unexpected event type received: %s\n", evt.Type)
@@ -190,3 +271,108 @@ func main() {
 	client.RunContext(ctx)
 
 }
+
+const (
+	approveActionID = "approve"
+	cancelActionID  = "cancel"
+)
+
+// groundControl formats the response of an operation to the user
+func groundControl(userID string, messages []string, err error) string {
+	if messages == nil {
+		messages = []string{}
+	}
+	if err != nil {
+		messages = append([]string{err.Error()}, messages...)
+	}
+
+	return fmt.Sprintf("<@%s> on ground control\n```%s```", userID, strings.Join(messages, "\n"))
+}
+
+// requestBlocks shows the summary of the request with the buttons to answer it
+func requestBlocks(r pending.Request) []slack.Block {
+	var fields []*slack.TextBlockObject
+	for _, f := range r.Fields {
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n`%s`", f.Name, f.Value), false, false))
+	}
+
+	label, approvers := "Confirm", fmt.Sprintf("<@%s> or an approver", r.CallerID)
+	if r.TwoPerson {
+		label, approvers = "Approve", fmt.Sprintf("An approver other than <@%s>", r.CallerID)
+	}
+	approve := slack.NewButtonBlockElement(approveActionID, r.ID, slack.NewTextBlockObject(slack.PlainTextType, label, false, false))
+	approve.Style = slack.StylePrimary
+	cancel := slack.NewButtonBlockElement(cancelActionID, r.ID, slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false))
+	cancel.Style = slack.StyleDanger
+
+	expiry := fmt.Sprintf("%s can answer before <!date^%d^{time}|%s>, or `/major-tom approve %s`", approvers, r.ExpireAt.Unix(), r.ExpireAt.Format(time.RFC3339), r.ID)
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "*"+r.Title+"*", false, false), fields, nil),
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, expiry, false, false)),
+		slack.NewActionBlock("", approve, cancel),
+	}
+}
+
+// runCommand runs the slash command and responds with its result, or with the request to confirm it
+func runCommand(ctx context.Context, api *slack.Client, clusters *k8sop.ClusterResolver, requests *pending.Store, cfg config.Config, auditSink audit.Sink, k8sRepoCFG config.KubernetesConfigsRepo, cmd slack.SlashCommand) {
+	thread := newThread(api, cmd, cfg.SlackBotToken != "")
+	messages, request, err := slashcommand.Run(command.WithFollowUp(ctx, thread), clusters, requests, cfg.Policy, auditSink, k8sRepoCFG, cmd.Command, cmd.Text, slashcommand.Caller{
+		ID:        cmd.UserID,
+		Name:      cmd.UserName,
+		ChannelID: cmd.ChannelID,
+	})
+	if err == nil && request != nil {
+		thread.respond(slack.MsgOptionText(request.Title, false), slack.MsgOptionBlocks(requestBlocks(*request)...))
+		return
+	}
+
+	thread.respond(slack.MsgOptionText(groundControl(cmd.UserID, messages, err), false))
+}
+
+// answerRequest approves or cancels the request of the button. The request message is replaced by the result so it can't be answered again, and the follow-ups of the approved command are posted after it
+func answerRequest(ctx context.Context, api *slack.Client, requests *pending.Store, policy config.Policy, auditSink audit.Sink, callback slack.InteractionCallback, action *slack.BlockAction) {
+	userID, channelID := callback.User.ID, callback.Channel.ID
+	thread := newAnswerThread(api, callback, userID)
+
+	var request pending.Request
+	var messages []string
+	var err error
+	switch action.ActionID {
+	case approveActionID:
+		request, messages, err = slashcommand.Approve(command.WithFollowUp(ctx, thread), requests, policy, auditSink, action.Value, slashcommand.Caller{
+			ID:        userID,
+			Name:      callback.User.Name,
+			ChannelID: channelID,
+		})
+	case cancelActionID:
+		request, err = requests.Cancel(action.Value, userID)
+	default:
+		logrus.Infof("Ignored action %s", action.ActionID)
+		thread.skip()
+		return
+	}
+
+	// the request is left for others to answer if the user is not allowed to
+	if _, errGet := requests.Get(action.Value); err != nil && errGet == nil {
+		thread.skip()
+		_, err = api.PostEphemeral(channelID, userID, slack.MsgOptionText(err.Error(), false))
+		if err != nil {
+			logrus.Errorf("failed posting message: %v", err)
+		}
+		return
+	}
+
+	var message string
+	switch {
+	case err == pending.ErrNotFound:
+		message = groundControl(userID, nil, err)
+	case action.ActionID == cancelActionID:
+		message = fmt.Sprintf("<@%s> cancelled: %s", userID, request.Title)
+	default:
+		message = groundControl(request.CallerID, messages, err)
+		if userID != request.CallerID {
+			message = fmt.Sprintf("%s\napproved by <@%s>", message, userID)
+		}
+	}
+	thread.respond(slack.MsgOptionText(message, false))
+}
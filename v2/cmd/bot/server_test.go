@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_newServeMux(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		isReady  bool
+		wantCode int
+	}{
+		{
+			name:     "healthy before kubernetes-configs is cloned",
+			path:     "/healthz",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "not ready before kubernetes-configs is cloned",
+			path:     "/readyz",
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "ready after kubernetes-configs is cloned",
+			path:     "/readyz",
+			isReady:  true,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "metrics",
+			path:     "/metrics",
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newServeMux(func() bool { return tt.isReady })
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("GET %s = %v, want %v", tt.path, w.Code, tt.wantCode)
+			}
+		})
+	}
+}
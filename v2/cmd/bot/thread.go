@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/slack-go/slack"
+)
+
+// thread responds to a slash command and posts its follow-ups in the thread of the response. The response is posted to the channel by the bot so its thread can be replied to, which requires the bot token. Otherwise, the response and the follow-ups are posted to the response URL
+type thread struct {
+	api         *slack.Client
+	channelID   string
+	responseURL string
+	userID      string
+	hasBotToken bool
+	// replaceOriginal makes the response replace the message of the response URL, like the request answered by a button
+	replaceOriginal bool
+
+	locker     sync.Mutex
+	isExpected bool
+	// ts is the timestamp of the response, which is set before posted is closed
+	ts     string
+	posted chan struct{}
+}
+
+func newThread(api *slack.Client, cmd slack.SlashCommand, hasBotToken bool) *thread {
+	return &thread{
+		api:         api,
+		channelID:   cmd.ChannelID,
+		responseURL: cmd.ResponseURL,
+		userID:      cmd.UserID,
+		hasBotToken: hasBotToken,
+		posted:      make(chan struct{}),
+	}
+}
+
+// newAnswerThread responds to the answer of a request by replacing the request message, so it can't be answered again. The follow-ups of the approved command are posted to the response URL of the request message
+func newAnswerThread(api *slack.Client, callback slack.InteractionCallback, userID string) *thread {
+	return &thread{
+		api:             api,
+		channelID:       callback.Channel.ID,
+		responseURL:     callback.ResponseURL,
+		userID:          userID,
+		replaceOriginal: true,
+		posted:          make(chan struct{}),
+	}
+}
+
+// Expect makes the response the parent of the follow-ups
+func (t *thread) Expect() {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	t.isExpected = true
+}
+
+// Notify makes the follow-ups mention the user
+func (t *thread) Notify(userID string) {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	t.userID = userID
+}
+
+// respond posts the response of the command. It must be called once
+func (t *thread) respond(options ...slack.MsgOption) {
+	defer close(t.posted)
+	t.locker.Lock()
+	isExpected := t.isExpected
+	t.locker.Unlock()
+
+	if isExpected && t.hasBotToken {
+		_, ts, err := t.api.PostMessage(t.channelID, options...)
+		if err == nil {
+			t.ts = ts
+			return
+		}
+		logrus.Errorf("failed posting message to channel %s, so it's posted to the response URL: %v", t.channelID, err)
+	}
+	option := slack.MsgOptionResponseURL(t.responseURL, "in_channel")
+	if t.replaceOriginal {
+		option = slack.MsgOptionReplaceOriginal(t.responseURL)
+	}
+	_, _, err := t.api.PostMessage(t.channelID, append([]slack.MsgOption{option}, options...)...)
+	if err != nil {
+		logrus.Errorf("failed posting message: %v", err)
+	}
+}
+
+// skip releases the follow-ups without a response, which are posted to the response URL
+func (t *thread) skip() {
+	close(t.posted)
+}
+
+// Post replies to the response once it's posted
+func (t *thread) Post(messages []string, err error) {
+	<-t.posted
+	option := slack.MsgOptionResponseURL(t.responseURL, "in_channel")
+	if t.ts != "" {
+		option = slack.MsgOptionTS(t.ts)
+	}
+	t.locker.Lock()
+	userID := t.userID
+	t.locker.Unlock()
+	_, _, err = t.api.PostMessage(t.channelID, option, slack.MsgOptionText(groundControl(userID, messages, err), false))
+	if err != nil {
+		logrus.Errorf("failed posting follow-up: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func Test_thread_Post(t *testing.T) {
+	tests := []struct {
+		name       string
+		notify     string
+		wantMember string
+	}{
+		{
+			name:       "follow-up of an answer",
+			wantMember: "<@U-approver>",
+		},
+		{
+			name:       "follow-up of a request approved for its caller",
+			notify:     "U-caller",
+			wantMember: "<@U-caller>",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			texts := make(chan string, 2)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var msg struct {
+					Text string `json:"text"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+					t.Errorf("decoding message has error: %v", err)
+				}
+				texts <- msg.Text
+				_, _ = w.Write([]byte(`{"ok":true}`))
+			}))
+			defer server.Close()
+
+			callback := slack.InteractionCallback{ResponseURL: server.URL}
+			callback.Channel.ID = "C1"
+			thread := newAnswerThread(slack.New("", slack.OptionAPIURL(server.URL+"/")), callback, "U-approver")
+			if tt.notify != "" {
+				thread.Notify(tt.notify)
+			}
+			thread.respond(slack.MsgOptionText("approved", false))
+			thread.Post([]string{"rolled out"}, nil)
+
+			<-texts
+			if got := <-texts; !strings.HasPrefix(got, tt.wantMember) {
+				t.Errorf("Post() posted %q, want it to mention %s", got, tt.wantMember)
+			}
+		})
+	}
+}
@@ -3,15 +3,20 @@ package command
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/mirror-media/major-tom-go/v2/config"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 // response is supposed to use in a channel to pass response for different go routine
 type response struct {
 	Messages []string
 	Error    error
+	// Commit is the hash of the commit pushed by a deployment
+	Commit string
 }
 
 func pop(slice []string, i int) (string, []string) {
@@ -34,7 +39,107 @@ func popValue(textParts []string, arg, delimeter string) (newTextParts []string,
 	if result == "" {
 		return textParts, "", errors.New(fmt.Sprintf("argument(%s) is expected", arg))
 	}
-	value = strings.Split(result, delimeter)[1]
+	pair := strings.Split(result, delimeter)
+	if len(pair) < 2 {
+		return textParts, "", errors.Errorf("argument(%s) expects %s<value>", arg, delimeter)
+	}
+	value = pair[1]
 
 	return textParts, value, nil
 }
+
+// findService resolves the service name to the codebase and the service it belongs to
+func findService(codebases []config.Codebase, name string) (*config.Codebase, *config.Service, error) {
+	for i := range codebases {
+		services, err := codebases[i].GetServices()
+		if err != nil {
+			logrus.Warnf("getting services of %s has error: %v", codebases[i].Repo, err)
+			continue
+		}
+		for j := range services {
+			if services[j].Name == name {
+				return &codebases[i], &services[j], nil
+			}
+		}
+	}
+	return nil, nil, errors.Errorf("service(%s) is not found", name)
+}
+
+// findCodebase returns the codebase of the repo
+func findCodebase(codebases []config.Codebase, repo string) (*config.Codebase, error) {
+	for i := range codebases {
+		if codebases[i].Repo == repo {
+			return &codebases[i], nil
+		}
+	}
+	return nil, errors.New("invalid repo name")
+}
+
+// popDryRun pops the optional dry-run argument
+func popDryRun(texts []string) (newTexts []string, dryRun bool, err error) {
+	return popBool(texts, "dry-run")
+}
+
+// popOverride pops the optional override argument to make a change in spite of the freezes
+func popOverride(texts []string) (newTexts []string, override bool, err error) {
+	return popBool(texts, "override")
+}
+
+// popImage pops the optional image argument choosing the kustomize image by its name
+func popImage(texts []string) (newTexts []string, image string) {
+	newTexts, image, _ = popValue(texts, "image", "=")
+	return newTexts, image
+}
+
+// popBool pops the optional boolean argument. It's false if the argument is not supplied
+func popBool(texts []string, arg string) (newTexts []string, value bool, err error) {
+	newTexts, text, errPop := popValue(texts, arg, "=")
+	// the argument is popped if it's supplied without a value
+	if errPop != nil && len(newTexts) == len(texts) {
+		return newTexts, false, nil
+	}
+	if errPop != nil {
+		return newTexts, false, errPop
+	}
+	value, err = strconv.ParseBool(text)
+	if err != nil {
+		return newTexts, false, errors.Errorf("%s(%s) should be true or false", arg, text)
+	}
+	return newTexts, value, nil
+}
+
+// Target returns the repo and the stage the command of the verb operates on, as far as texts tell. texts is interpreted as the command does, and a repo or a service not found is returned as is
+func Target(k8sRepo config.KubernetesConfigsRepo, verb string, texts []string) (repo, stage string) {
+	switch verb {
+	case "deploy", "release", "scale", "rollback", "promote", "list", "info", "reconcile", "freeze", "unfreeze":
+	default:
+		return "", ""
+	}
+	if len(texts) == 0 {
+		return "", ""
+	}
+
+	// popping changes the underlying array which is still to be run
+	args := append([]string(nil), texts...)
+	switch verb {
+	case "release":
+		stage = "prod"
+	case "promote":
+		_, stage, _ = popValue(args, "to", "=")
+	default:
+		_, stage, _ = popValue(args, "env", "=")
+	}
+
+	name := texts[0]
+	if strings.Contains(name, "=") {
+		// list of services and freezes have no repo
+		return "", stage
+	}
+	if codebase, err := findCodebase(k8sRepo.Configs, name); err == nil {
+		return codebase.Repo, stage
+	}
+	if codebase, _, err := findService(k8sRepo.Configs, name); err == nil {
+		return codebase.Repo, stage
+	}
+	return name, stage
+}
@@ -1,2 +1,357 @@
 // Package command implement the operation controller
 package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/internal/test"
+)
+
+func Test_findService(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceName string
+		wantRepo    string
+		wantService config.Service
+		wantErr     bool
+	}{
+		{
+			name:        "type 2 service",
+			serviceName: "openwarehouse-tv-gql-external",
+			wantRepo:    "openwarehouse",
+			wantService: config.Service{
+				Name:          "openwarehouse-tv-gql-external",
+				Project:       "tv",
+				Repo:          "openwarehouse",
+				SimpleService: "gql-external",
+			},
+		},
+		{
+			name:        "type 1 service is the repo",
+			serviceName: "mirror-tv-nuxt",
+			wantRepo:    "mirror-tv-nuxt",
+			wantService: config.Service{
+				Name: "mirror-tv-nuxt",
+				Repo: "mirror-tv-nuxt",
+			},
+		},
+		{
+			name:        "repo of type 2 is not a service",
+			serviceName: "openwarehouse",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codebase, service, err := findService(test.K8sRepo.Configs, tt.serviceName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("findService() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if codebase.Repo != tt.wantRepo {
+				t.Errorf("findService() codebase = %v, want %v", codebase.Repo, tt.wantRepo)
+			}
+			if !reflect.DeepEqual(*service, tt.wantService) {
+				t.Errorf("findService() service = %+v, want %+v", *service, tt.wantService)
+			}
+		})
+	}
+}
+
+func Test_popReplicas(t *testing.T) {
+	tests := []struct {
+		name         string
+		texts        []string
+		wantTexts    []string
+		wantReplicas int
+		wantErr      bool
+	}{
+		{
+			name:         "absent argument is 0",
+			texts:        []string{"env=dev"},
+			wantTexts:    []string{"env=dev"},
+			wantReplicas: 0,
+		},
+		{
+			name:         "positive number",
+			texts:        []string{"env=dev", "maxReplicas=3"},
+			wantTexts:    []string{"env=dev"},
+			wantReplicas: 3,
+		},
+		{
+			name:    "zero is not allowed",
+			texts:   []string{"maxReplicas=0"},
+			wantErr: true,
+		},
+		{
+			name:    "negative number is not allowed",
+			texts:   []string{"maxReplicas=-1"},
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			texts:   []string{"maxReplicas=three"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTexts, gotReplicas, err := popReplicas(tt.texts, "maxReplicas")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("popReplicas() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(gotTexts, tt.wantTexts) {
+				t.Errorf("popReplicas() texts = %v, want %v", gotTexts, tt.wantTexts)
+			}
+			if gotReplicas != tt.wantReplicas {
+				t.Errorf("popReplicas() replicas = %v, want %v", gotReplicas, tt.wantReplicas)
+			}
+		})
+	}
+}
+
+func Test_popDryRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		texts      []string
+		wantTexts  []string
+		wantDryRun bool
+		wantErr    bool
+	}{
+		{
+			name:      "absent argument is not a dry run",
+			texts:     []string{"env=dev"},
+			wantTexts: []string{"env=dev"},
+		},
+		{
+			name:       "dry run",
+			texts:      []string{"env=dev", "dry-run=true"},
+			wantTexts:  []string{"env=dev"},
+			wantDryRun: true,
+		},
+		{
+			name:      "explicitly not a dry run",
+			texts:     []string{"dry-run=false", "env=dev"},
+			wantTexts: []string{"env=dev"},
+		},
+		{
+			name:    "invalid value",
+			texts:   []string{"dry-run=maybe"},
+			wantErr: true,
+		},
+		{
+			name:    "no value",
+			texts:   []string{"env=dev", "dry-run"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTexts, gotDryRun, err := popDryRun(tt.texts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("popDryRun() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(gotTexts, tt.wantTexts) {
+				t.Errorf("popDryRun() texts = %v, want %v", gotTexts, tt.wantTexts)
+			}
+			if gotDryRun != tt.wantDryRun {
+				t.Errorf("popDryRun() dryRun = %v, want %v", gotDryRun, tt.wantDryRun)
+			}
+		})
+	}
+}
+
+func TestTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		verb      string
+		texts     []string
+		wantRepo  string
+		wantStage string
+	}{
+		{
+			name:      "deploy a repo",
+			verb:      "deploy",
+			texts:     []string{"openwarehouse", "env=dev", "image-tag=dev_1bac23"},
+			wantRepo:  "openwarehouse",
+			wantStage: "dev",
+		},
+		{
+			name:      "release is in prod",
+			verb:      "release",
+			texts:     []string{"openwarehouse", "project=tv", "image-tag=prod_81ab7ac"},
+			wantRepo:  "openwarehouse",
+			wantStage: "prod",
+		},
+		{
+			name:      "scale a service of a repo",
+			verb:      "scale",
+			texts:     []string{"openwarehouse-tv-gql-external", "maxReplicas=3", "env=prod"},
+			wantRepo:  "openwarehouse",
+			wantStage: "prod",
+		},
+		{
+			name:      "promote to a stage",
+			verb:      "promote",
+			texts:     []string{"mirror-tv-nuxt", "from=staging", "to=prod"},
+			wantRepo:  "mirror-tv-nuxt",
+			wantStage: "prod",
+		},
+		{
+			name:      "list services of a project",
+			verb:      "list",
+			texts:     []string{"project=tv", "env=staging"},
+			wantStage: "staging",
+		},
+		{
+			name:      "freeze has no repo",
+			verb:      "freeze",
+			texts:     []string{"env=prod", "reason=election"},
+			wantStage: "prod",
+		},
+		{
+			name:     "unknown repo is returned as is",
+			verb:     "deploy",
+			texts:    []string{"unknown"},
+			wantRepo: "unknown",
+		},
+		{
+			name:  "verb without target",
+			verb:  "approvals",
+			texts: []string{"0a1b2c3d"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			texts := append([]string(nil), tt.texts...)
+			gotRepo, gotStage := Target(test.K8sRepo, tt.verb, texts)
+			if gotRepo != tt.wantRepo || gotStage != tt.wantStage {
+				t.Errorf("Target() = (%v, %v), want (%v, %v)", gotRepo, gotStage, tt.wantRepo, tt.wantStage)
+			}
+			if !reflect.DeepEqual(texts, tt.texts) {
+				t.Errorf("Target() changed texts to %v", texts)
+			}
+		})
+	}
+}
+
+func Test_popRest(t *testing.T) {
+	tests := []struct {
+		name      string
+		texts     []string
+		wantTexts []string
+		wantValue string
+	}{
+		{
+			name:      "value with spaces",
+			texts:     []string{"project=tv", "reason=election", "night", "coverage"},
+			wantTexts: []string{"project=tv"},
+			wantValue: "election night coverage",
+		},
+		{
+			name:      "absent argument",
+			texts:     []string{"project=tv"},
+			wantTexts: []string{"project=tv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTexts, gotValue := popRest(tt.texts, "reason", "=")
+			if !reflect.DeepEqual(gotTexts, tt.wantTexts) {
+				t.Errorf("popRest() texts = %v, want %v", gotTexts, tt.wantTexts)
+			}
+			if gotValue != tt.wantValue {
+				t.Errorf("popRest() value = %v, want %v", gotValue, tt.wantValue)
+			}
+		})
+	}
+}
+
+func Test_popProjects(t *testing.T) {
+	openwarehouse := config.Codebase{
+		Type:     2,
+		Repo:     "openwarehouse",
+		Stages:   []string{"dev", "staging", "prod"},
+		Projects: []string{"tv", "readr", "weekly"},
+	}
+	tests := []struct {
+		name         string
+		texts        []string
+		codebase     config.Codebase
+		wantProjects []string
+		wantErr      bool
+	}{
+		{
+			name:         "one project",
+			texts:        []string{"project=tv"},
+			codebase:     openwarehouse,
+			wantProjects: []string{"tv"},
+		},
+		{
+			name:         "all projects",
+			texts:        []string{"project=all"},
+			codebase:     openwarehouse,
+			wantProjects: []string{"tv", "readr", "weekly"},
+		},
+		{
+			name:         "listed projects",
+			texts:        []string{"projects=tv,readr"},
+			codebase:     openwarehouse,
+			wantProjects: []string{"tv", "readr"},
+		},
+		{
+			name:     "project not supported",
+			texts:    []string{"projects=tv,mirror"},
+			codebase: openwarehouse,
+			wantErr:  true,
+		},
+		{
+			name:     "project listed twice",
+			texts:    []string{"projects=tv,tv"},
+			codebase: openwarehouse,
+			wantErr:  true,
+		},
+		{
+			name:     "both project and projects",
+			texts:    []string{"project=tv", "projects=readr"},
+			codebase: openwarehouse,
+			wantErr:  true,
+		},
+		{
+			name:     "all projects of a type 1 codebase",
+			texts:    []string{"project=all"},
+			codebase: test.K8sRepo.Configs[1],
+			wantErr:  true,
+		},
+		{
+			name:     "no project",
+			texts:    []string{"image-tag=prod_399440e"},
+			codebase: openwarehouse,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got, err := popProjects(tt.texts, tt.codebase)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("popProjects() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.wantProjects) {
+				t.Errorf("popProjects() = %v, want %v", got, tt.wantProjects)
+			}
+		})
+	}
+}
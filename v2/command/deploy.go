@@ -4,16 +4,23 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-git/go-git/v5/plumbing"
-	gootkitconfig "github.com/gookit/config/v2"
-	"github.com/gookit/config/v2/yaml"
+	"github.com/mirror-media/major-tom-go/v2/audit"
 	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/freeze"
 	"github.com/mirror-media/major-tom-go/v2/gitop"
 	mjcontext "github.com/mirror-media/major-tom-go/v2/internal/context"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"github.com/mirror-media/major-tom-go/v2/metrics"
+	"github.com/mirror-media/major-tom-go/v2/registry"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -23,21 +30,60 @@ type yamlCFG struct {
 	valueType string
 }
 
+// fileEdit is a change to a YAML file in kubernetes-configs. edit modifies the loaded YAML and returns the description of the changes
+type fileEdit struct {
+	path string
+	// missing is reported instead of the file error if the file doesn't exist
+	missing error
+	edit    func(doc *yamlop.Document) (changes []string, err error)
+}
+
 type Deployment struct {
 	ctx      context.Context
 	codebase *config.Codebase
 	service  *config.Service
 	project  string
-	stage    string
-	imageTag string
-	caller   string
-	message  string
+	// batchProjects are the projects changed together by the deployment, if it's more than one project
+	batchProjects []string
+	stage         string
+	// imageTags are the images set by the deployment, which are all set in one commit
+	imageTags []imageTag
+	caller    string
+	message   string
+	// title is the first line of the commit message
+	title string
+	edits []fileEdit
+	// plan resolves more edits against the up-to-date kubernetes-configs if they depend on its content
+	plan func(repo *gitop.Repository) ([]fileEdit, error)
+	// dryRun reports the diff and the commit message instead of committing them
+	dryRun bool
+	// override makes the change in spite of the freezes
+	override bool
+}
+
+// projects are the ones affected by the deployment. A deployment without project affects all projects of the codebase
+func (d Deployment) projects() []string {
+	if len(d.batchProjects) != 0 {
+		return d.batchProjects
+	}
+	if d.project != "" {
+		return []string{d.project}
+	}
+	return d.codebase.Projects
 }
 
 var deployChannel = make(chan Deployment, 64)
 
-// Deploy certain configuration to a service. textParts in interpreted as [project, stage, service, ...cfg:arg]
-func Deploy(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []string, message, caller string) (messages []string, err error) {
+// query reads kubernetes-configs in the deploy worker, so it won't interfere with a deployment in progress
+type query struct {
+	ctx  context.Context
+	read func(repo *gitop.Repository) (messages []string, err error)
+}
+
+var queryChannel = make(chan query, 64)
+
+// Deploy certain configuration to a service. textParts in interpreted as [project, stage, service, ...cfg:arg]. With wait=true, the rollout on the clusters is posted to the follow-up in ctx
+func Deploy(ctx context.Context, clusters *k8sop.ClusterResolver, k8sRepo config.KubernetesConfigsRepo, texts []string, message, caller string) (messages []string, err error) {
 	if !DeployWorker.isRunning {
 		return nil, errors.New("deploy worker is not running")
 	}
@@ -70,45 +116,242 @@ func Deploy(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []s
 		return nil, errors.New("deploy command doesn't support prod env")
 	}
 
-	texts, image, err := popValue(texts, "image-tag", "=")
+	texts, tags, err := popImageTags(texts)
 	if err != nil {
 		return nil, errors.Wrap(err, "getting image-tag for deployment encountered an error")
 	}
 
+	texts, isDryRun, err := popDryRun(texts)
+	if err != nil {
+		return nil, err
+	}
+	texts, isOverride, err := popOverride(texts)
+	if err != nil {
+		return nil, err
+	}
+	texts, isWait, err := popBool(texts, "wait")
+	if err != nil {
+		return nil, err
+	}
+
 	if len(texts) != 0 {
 		return nil, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
 	}
 
-	timeout := 5 * time.Minute
+	// the rollout is watched if it's waited for or rolled back on failure, but a dry run has nothing to watch
+	isAutoRollback := codebase.AutoRollback.Enabled && !isDryRun
+	isWatched := isAutoRollback || (isWait && !isDryRun)
+	followUp := followUpFrom(ctx)
+	var targets []rolloutTarget
+	timeout := DeployWorker.getRolloutTimeout()
+	if isWatched {
+		if isWait && followUp == nil {
+			return nil, errors.New("wait=true is not supported here")
+		}
+		targets, err = rolloutTargets(clusters, *codebase, stage, codebase.Projects)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if isAutoRollback {
+		timeout, err = codebase.AutoRollback.GetTimeout(timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = checkImageTags(k8sRepo, *codebase, stage, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	edits, err := imageTagEdits(*codebase, stage, "", tags)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyImageTags(ctx, *codebase, []string{edits[0].path}, tags, message)
+	if err != nil {
+		return nil, err
+	}
+	var refs []imageTag
+	if isWatched {
+		refs, err = resolveImageTags(ctx, *codebase, []string{edits[0].path}, tags, message)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// previous are the image tags replaced by the deployment, which are read by the deploy worker right before the change
+	path := edits[0].path
+	var previous []imageTag
+	var plan func(repo *gitop.Repository) ([]fileEdit, error)
+	if isAutoRollback {
+		plan = func(repo *gitop.Repository) ([]fileEdit, error) {
+			previous = readPreviousTags(repo, path, *codebase, tags)
+			return nil, nil
+		}
+	}
+
+	pushed := queueDeployment(ctx, Deployment{
+		codebase:  codebase,
+		stage:     stage,
+		imageTags: tags,
+		caller:    caller,
+		message:   message,
+		title:     deployTitle(codebase.Repo, stage, "", caller),
+		edits:     edits,
+		plan:      plan,
+		dryRun:    isDryRun,
+		override:  isOverride,
+	})
+	messages, err = pushed.Messages, pushed.Error
+	if err == nil && pushed.Commit != "" {
+		messages = append(messages, autoReconcile(ctx, clusters, *codebase, stage, codebase.Projects)...)
+	}
+	if err != nil || !isWatched {
+		return messages, err
+	}
+
+	watch := rolloutWatch{
+		targets: targets,
+		path:    path,
+		commit:  pushed.Commit,
+		tags:    refs,
+		timeout: timeout,
+	}
+	waiting := fmt.Sprintf("waiting up to %s for the rollout", timeout)
+	if isAutoRollback {
+		watch.rollback = func() ([]string, error) {
+			return autoRollback(codebase, stage, "", path, tags, previous, fmt.Sprintf("auto-rollback of \"%s\"", message))
+		}
+		waiting += ", which is rolled back if it fails"
+	}
+	watchRollouts(followUp, message, []rolloutWatch{watch})
+	return append(messages, waiting), nil
+}
+
+// timeoutError is returned if the deploy worker doesn't respond in time
+type timeoutError struct {
+	message string
+	timeout time.Duration
+}
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("\"%s\" command has timeouted(%f)", e.message, e.timeout.Minutes())
+}
+
+// IsTimeout reports whether the command timed out waiting for the deploy worker
+func IsTimeout(err error) bool {
+	_, ok := errors.Cause(err).(timeoutError)
+	return ok
+}
+
+// ask sends the read to the deploy worker and waits for its response
+func ask(ctx context.Context, message string, read func(repo *gitop.Repository) (messages []string, err error)) (messages []string, err error) {
+	timeout := 1 * time.Minute
 	ch := make(chan response)
 	newCtx := context.WithValue(ctx, mjcontext.ResponseChannel, ch)
 	newCtx, cancelFn := context.WithTimeout(newCtx, timeout)
 	defer cancelFn()
-	deployChannel <- Deployment{
-		ctx:      newCtx,
-		codebase: codebase,
-		stage:    stage,
-		imageTag: image,
-		caller:   caller,
-		message:  message,
+	queryChannel <- query{
+		ctx:  newCtx,
+		read: read,
 	}
 
 	select {
 	case commandResponse := <-ch:
 		return commandResponse.Messages, commandResponse.Error
 	case <-newCtx.Done():
-		return nil, errors.Errorf("\"%s\" command has timeouted(%f)", strings.Join(texts, " "), timeout.Minutes())
+		return nil, timeoutError{message: message, timeout: timeout}
 	}
 }
 
+// queue sends the deployment to the deploy worker and waits for its response. It's refused if the stage is frozen, unless it's a dry run or an override
+func queue(ctx context.Context, deployment Deployment) (messages []string, err error) {
+	r := queueDeployment(ctx, deployment)
+	return r.Messages, r.Error
+}
+
+// queueDeployment is queue returning the commit pushed as well
+func queueDeployment(ctx context.Context, deployment Deployment) response {
+	if !deployment.dryRun && !deployment.override {
+		err := DeployWorker.freezes.Check(deployment.projects(), deployment.stage)
+		if err != nil {
+			return response{Error: errors.Errorf("%s. Add override=true to change it anyway", err)}
+		}
+	}
+
+	timeout := 5 * time.Minute
+	ch := make(chan response)
+	newCtx := context.WithValue(ctx, mjcontext.ResponseChannel, ch)
+	newCtx, cancelFn := context.WithTimeout(newCtx, timeout)
+	defer cancelFn()
+	deployment.ctx = newCtx
+	deployChannel <- deployment
+
+	select {
+	case commandResponse := <-ch:
+		return commandResponse
+	case <-newCtx.Done():
+		return response{Error: timeoutError{message: deployment.message, timeout: timeout}}
+	}
+}
+
+func deployTitle(repo, stage, project, caller string) string {
+	var pendingProject string
+
+	if project != "" {
+		pendingProject = "/" + project
+	}
+	return fmt.Sprintf("deploy(%s/%s%s): deployed by %s", repo, stage, pendingProject, caller)
+}
+
 type deployWorker struct {
 	once      sync.Once
 	isRunning bool
-	k8sRepo   *gitop.Repository
+	// ready is 1 once kubernetes-configs is cloned, and it's read by the readiness probe
+	ready    int32
+	k8sRepo  *gitop.Repository
+	freezes  *freeze.Store
+	registry registry.Client
+	// rolloutTimeout is how long wait=true waits for the rollout
+	rolloutTimeout time.Duration
 }
 
 var DeployWorker deployWorker
 
+// SetFreezes sets the freezes checked before queueing a deployment
+func (w *deployWorker) SetFreezes(freezes *freeze.Store) {
+	w.freezes = freezes
+}
+
+// SetRolloutTimeout sets how long wait=true waits for the rollout
+func (w *deployWorker) SetRolloutTimeout(timeout time.Duration) {
+	w.rolloutTimeout = timeout
+}
+
+func (w *deployWorker) getRolloutTimeout() time.Duration {
+	if w.rolloutTimeout <= 0 {
+		return config.DefaultRolloutTimeout
+	}
+	return w.rolloutTimeout
+}
+
+// IsReady reports whether kubernetes-configs is cloned and the worker is running
+func (w *deployWorker) IsReady() bool {
+	return atomic.LoadInt32(&w.ready) == 1
+}
+
+// QueueDepth returns the number of the deployments waiting for the worker
+func QueueDepth() int {
+	return len(deployChannel)
+}
+
+// SetRegistry sets the registry where the image tags are verified before they are deployed
+func (w *deployWorker) SetRegistry(client registry.Client) {
+	w.registry = client
+}
+
 func (w *deployWorker) Set(gitConfigs config.GitConfig) {
 	var err error
 	w.k8sRepo, err = gitop.GetK8SConfigsRepository(gitConfigs)
@@ -117,12 +360,17 @@ func (w *deployWorker) Set(gitConfigs config.GitConfig) {
 	}
 
 	w.once.Do(func() {
+		atomic.StoreInt32(&w.ready, 1)
 		w.isRunning = true
 		logrus.Info("the deploy worker is running now....")
 		go func() {
 			for {
-				deployment := <-deployChannel
-				deploy(deployment.ctx, w.k8sRepo, *deployment.codebase, deployment.stage, deployment.project, deployment.imageTag, deployment.message, deployment.caller)
+				select {
+				case deployment := <-deployChannel:
+					deploy(w.k8sRepo, deployment)
+				case q := <-queryChannel:
+					read(q.ctx, w.k8sRepo, q.read)
+				}
 			}
 		}()
 	})
@@ -132,14 +380,16 @@ func hardReset(repository *gitop.Repository, commit plumbing.Hash) (hardResetFN
 	return func() error { return repository.HardResetToCommit(commit) }
 }
 
-// deploy certain configuration to a service. textParts in interpreted as [repo, env=value..., ...cfg=value]
-func deploy(ctx context.Context, k8sRepo *gitop.Repository, codebase config.Codebase, stage, project, imageTag, message, caller string) {
+// deploy applies the edits of the deployment to kubernetes-configs and pushes them as one commit. The worktree is hard reset if any step fails
+func deploy(k8sRepo *gitop.Repository, deployment Deployment) {
 	var messages []string
 	var err error
-	ch := ctx.Value(mjcontext.ResponseChannel).(chan response)
+	ch := deployment.ctx.Value(mjcontext.ResponseChannel).(chan response)
+	project, message, caller := deployment.project, deployment.message, deployment.caller
 
 	repo := k8sRepo
 
+	start := time.Now()
 	err = repo.Pull()
 	if err != nil {
 		err = errors.Wrap(err, fmt.Sprintf("pulling repo for project(%s) has error", project))
@@ -149,6 +399,7 @@ func deploy(ctx context.Context, k8sRepo *gitop.Repository, codebase config.Code
 		}
 		return
 	}
+	metrics.ObservePhase(metrics.PhasePull, start)
 
 	hash, errHash := repo.GetHeadHash()
 	if errHash != nil {
@@ -164,128 +415,216 @@ func deploy(ctx context.Context, k8sRepo *gitop.Repository, codebase config.Code
 	}
 	hardResetFn := hardReset(repo, hash)
 
-	path, err := codebase.GetImageKustomizationPath(stage, project)
-	if err != nil {
-		ch <- response{
-			Messages: messages,
-			Error:    err,
+	start = time.Now()
+	edits := deployment.edits
+	if deployment.plan != nil {
+		planned, err := deployment.plan(repo)
+		if err != nil {
+			ch <- response{
+				Messages: messages,
+				Error:    err,
+			}
+			return
 		}
-		return
+		edits = append(edits, planned...)
 	}
-	kustomization, err := repo.GetFile(path)
-	if err != nil {
-		err = errors.Wrap(err, fmt.Sprintf("cannot get file(%s)", path))
-		ch <- response{
-			Messages: messages,
-			Error:    err,
+
+	trace := audit.TraceFrom(deployment.ctx)
+	// original content of the files to show the diff in a dry run
+	originals := make(map[string][]byte)
+	var changes []string
+	for _, e := range edits {
+		trace.AddPaths(e.path)
+		if _, isRead := originals[e.path]; deployment.dryRun && !isRead {
+			originals[e.path], _ = repo.ReadFile(e.path)
 		}
-		return
+		c, err := editFile(repo, e)
+		if err != nil {
+			ch <- response{
+				Messages: messages,
+				Error:    err,
+			}
+			_ = hardResetFn()
+			return
+		}
+		changes = append(changes, c...)
 	}
+	metrics.ObservePhase(metrics.PhaseEdit, start)
 
-	f := kustomization
+	trace.AddChanges(changes...)
+	messages = append(messages, deployment.title, "")
+	messages = append(messages, changes...)
+	messages = append(messages, "", fmt.Sprintf("by \"%s\"", message))
 
-	// operation starts here. worktree needs to be cleaned if disaster happens
-	b, err := io.ReadAll(f)
-	if err != nil {
-		err = errors.Wrap(err, fmt.Sprintf("reading %s has error", path))
-		logrus.Warn(err)
-		ch <- response{
-			Messages: messages,
-			Error:    err,
-		}
+	if deployment.dryRun {
+		ch <- dryRun(repo, edits, originals, messages)
 		_ = hardResetFn()
 		return
 	}
-	valueConfig := gootkitconfig.New(path)
-	valueConfig.AddDriver(yaml.Driver)
-	err = valueConfig.LoadStrings(gootkitconfig.Yaml, string(b))
+
+	// command operation finished
+	// now git operations starts
+
+	start = time.Now()
+	for _, e := range edits {
+		err = repo.AddFile(e.path)
+		if err != nil {
+			ch <- response{
+				Messages: messages,
+				Error:    errors.Wrap(err, fmt.Sprintf("adding %s to staging area has error", e.path)),
+			}
+			_ = hardResetFn()
+			return
+		}
+	}
+
+	approver, _ := deployment.ctx.Value(mjcontext.Approver).(string)
+	err = repo.Commit(edits[0].path, caller, approver, strings.Join(messages, "\n"))
 	if err != nil {
-		err = errors.Wrap(err, fmt.Sprintf("loading YAML from %s has error", path))
-		logrus.Warn(err)
 		ch <- response{
-			Messages: messages,
-			Error:    err,
+			Messages: append([]string{"this operation failed"}, messages...),
+			Error:    errors.Wrap(err, fmt.Sprintf("commits for project(%s) has error", project)),
 		}
 		_ = hardResetFn()
 		return
 	}
+	metrics.ObservePhase(metrics.PhaseCommit, start)
 
-	images0 := valueConfig.Get(".images.0", true).(map[interface{}]interface{})
-	images0["newTag"] = imageTag
-	err = valueConfig.Set(".images.0", images0, true)
+	start = time.Now()
+	err = repo.Push()
 	if err != nil {
+		metrics.GitPushFailures.Inc()
 		ch <- response{
-			Messages: messages,
-			Error:    errors.Wrap(err, fmt.Sprintf("fail to set newTag in %s", path)),
+			Messages: append([]string{"this operation failed"}, messages...),
+			Error:    errors.Wrap(err, fmt.Sprintf("push commits for project(%s) has error", project)),
 		}
 		_ = hardResetFn()
 		return
 	}
+	metrics.ObservePhase(metrics.PhasePush, start)
+	var commit string
+	if pushed, errHash := repo.GetHeadHash(); errHash == nil {
+		commit = pushed.String()
+		trace.SetCommit(commit)
+	}
 
-	var pendingProject string
-
-	if project != "" {
-		pendingProject = "/" + project
+	ch <- response{
+		Messages: messages,
+		Error:    err,
+		Commit:   commit,
 	}
+}
 
-	messages = append(messages, fmt.Sprintf("deploy(%s/%s%s): deployed by %s", codebase.Repo, stage, pendingProject, caller), "", fmt.Sprintf("Set %s(%s) to %v", "image-tag", "images.0.newTag", imageTag), "", fmt.Sprintf("by \"%s\"", message))
+// read pulls kubernetes-configs before reading it
+func read(ctx context.Context, repo *gitop.Repository, readFn func(repo *gitop.Repository) (messages []string, err error)) {
+	ch := ctx.Value(mjcontext.ResponseChannel).(chan response)
 
-	err = f.Truncate(0)
-	f.Close()
+	err := repo.Pull()
 	if err != nil {
 		ch <- response{
-			Messages: messages,
-			Error:    errors.Wrap(err, fmt.Sprintf("clean file before writing to %s has error", path)),
+			Error: errors.Wrap(err, "pulling repo has error"),
 		}
-		_ = hardResetFn()
 		return
 	}
 
-	f, _ = repo.GetFile(path)
-	valueConfig.DumpTo(f, gootkitconfig.Yaml)
-	if err != nil {
-		ch <- response{
-			Messages: messages,
-			Error:    errors.Wrap(err, fmt.Sprintf("writing to %s has error", path)),
+	messages, err := readFn(repo)
+	ch <- response{
+		Messages: messages,
+		Error:    err,
+	}
+}
+
+// dryRun reports the commit message and the diff of the edited files
+func dryRun(repo *gitop.Repository, edits []fileEdit, originals map[string][]byte, messages []string) response {
+	result := append([]string{"dry run: nothing is committed or pushed", "", "commit message:"}, messages...)
+	result = append(result, "", "diff:")
+	for _, e := range edits {
+		original, isExisting := originals[e.path]
+		if !isExisting {
+			continue
 		}
-		_ = hardResetFn()
-		return
+		delete(originals, e.path)
+		edited, err := repo.ReadFile(e.path)
+		if err != nil {
+			return response{
+				Messages: result,
+				Error:    errors.Wrap(err, fmt.Sprintf("cannot get file(%s)", e.path)),
+			}
+		}
+		result = append(result, unifiedDiff(e.path, string(original), string(edited))...)
 	}
+	return response{
+		Messages: result,
+	}
+}
 
-	// command operation finished
-	// now git operations starts
+// editFile loads the YAML of the edit from the worktree, applies the edit and writes it back
+func editFile(repo *gitop.Repository, e fileEdit) (changes []string, err error) {
+	path := e.path
+	f, err := repo.GetFile(path)
+	if os.IsNotExist(err) && e.missing != nil {
+		return nil, e.missing
+	} else if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("cannot get file(%s)", path))
+	}
 
-	repo.AddFile(path)
+	// operation starts here. worktree needs to be cleaned if disaster happens
+	b, err := io.ReadAll(f)
 	if err != nil {
-		ch <- response{
-			Messages: messages,
-			Error:    errors.Wrap(err, fmt.Sprintf("adding %s to staging area has error", path)),
-		}
-		_ = hardResetFn()
-		return
+		f.Close()
+		err = errors.Wrap(err, fmt.Sprintf("reading %s has error", path))
+		logrus.Warn(err)
+		return nil, err
+	}
+	doc, err := loadYAML(path, b)
+	if err != nil {
+		f.Close()
+		logrus.Warn(err)
+		return nil, err
 	}
 
-	err = repo.Commit(path, caller, strings.Join(messages, "\n"))
+	changes, err = e.edit(doc)
 	if err != nil {
-		ch <- response{
-			Messages: append([]string{"this operation failed"}, messages...),
-			Error:    errors.Wrap(err, fmt.Sprintf("commits for project(%s) has error", project)),
-		}
-		_ = hardResetFn()
-		return
+		f.Close()
+		return nil, err
 	}
-	err = repo.Push()
+
+	err = f.Truncate(0)
+	f.Close()
 	if err != nil {
-		ch <- response{
-			Messages: append([]string{"this operation failed"}, messages...),
-			Error:    errors.Wrap(err, fmt.Sprintf("push commits for project(%s) has error", project)),
-		}
-		_ = hardResetFn()
-		return
+		return nil, errors.Wrap(err, fmt.Sprintf("clean file before writing to %s has error", path))
 	}
 
-	ch <- response{
-		Messages: messages,
-		Error:    err,
+	f, err = repo.GetFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("cannot get file(%s)", path))
+	}
+	defer f.Close()
+	_, err = f.Write(doc.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("writing to %s has error", path))
+	}
+	return changes, nil
+}
+
+// loadYAML loads the content of the YAML file at path
+func loadYAML(path string, b []byte) (*yamlop.Document, error) {
+	doc, err := yamlop.Parse(b)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("loading YAML from %s has error", path))
+	}
+	return doc, nil
+}
+
+// getInt returns the number at path, or defaultValue if it's absent or not a number
+func getInt(doc *yamlop.Document, path string, defaultValue int) int {
+	value, err := doc.Get(path)
+	if err != nil {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
 	}
+	return i
 }
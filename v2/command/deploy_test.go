@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"regexp"
 	"testing"
 	"time"
 
@@ -67,7 +68,7 @@ func TestDeploy(t *testing.T) {
 				texts:   []string{"openwarehouse", "env=dev", "image-tag=" + newTag},
 				message: "deploy openwarehouse env=dev image-tag=" + newTag,
 			},
-			wantMessages: []string{"deploy(openwarehouse/dev): deployed by +tester", "", "Set image-tag(images.0.newTag) to " + newTag, "by \"deploy openwarehouse env=dev image-tag=" + newTag + "\""},
+			wantMessages: []string{"deploy(openwarehouse/dev): deployed by +tester", "", "Set image-tag(images.0.newTag) of gcr.io/mirrormedia-1470651750304/openwarehouse from " + deployedTag + " to " + newTag, "by \"deploy openwarehouse env=dev image-tag=" + newTag + "\""},
 		},
 		{
 			name: "deploy env mirror-tv-nuxt image-tag",
@@ -78,7 +79,7 @@ func TestDeploy(t *testing.T) {
 				texts:   []string{"mirror-tv-nuxt", "env=dev", "image-tag=" + newTag},
 				message: "deploy mirror-tv-nuxt env=dev image-tag=" + newTag,
 			},
-			wantMessages: []string{"deploy(mirror-tv-nuxt/dev): deployed by +tester", "", "Set image-tag(images.0.newTag) to " + newTag, "by \"deploy mirror-tv-nuxt env=dev image-tag=" + newTag + "\""},
+			wantMessages: []string{"deploy(mirror-tv-nuxt/dev): deployed by +tester", "", "Set image-tag(images.0.newTag) of gcr.io/mirrormedia-1470651750304/mirror-tv-nuxt from " + deployedTag + " to " + newTag, "by \"deploy mirror-tv-nuxt env=dev image-tag=" + newTag + "\""},
 		},
 		{
 			name: "can't deploy prod image-tag",
@@ -95,14 +96,28 @@ func TestDeploy(t *testing.T) {
 	DeployWorker.Set(test.K8sRepo.Git)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotMessages, err := Deploy(tt.args.ctx, tt.args.k8sRepo, tt.args.texts, tt.args.message, tt.args.caller)
+			gotMessages, err := Deploy(tt.args.ctx, nil, tt.args.k8sRepo, tt.args.texts, tt.args.message, tt.args.caller)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Deploy() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(gotMessages, tt.wantMessages) {
+			if !reflect.DeepEqual(maskDeployedTag(gotMessages), tt.wantMessages) {
 				t.Errorf("Deploy() = %+v, want %+v", gotMessages, tt.wantMessages)
 			}
 		})
 	}
 }
+
+// deployedTag stands for the tag replaced in the test branch, which is left by the last run
+const deployedTag = "<deployed>"
+
+var deployedTagPattern = regexp.MustCompile(`^(Set image-tag\(images\.\d+\.newTag\) of \S+ from )\S+( to \S+)$`)
+
+// maskDeployedTag replaces the tag replaced by the change with deployedTag
+func maskDeployedTag(messages []string) []string {
+	masked := make([]string, len(messages))
+	for i, m := range messages {
+		masked[i] = deployedTagPattern.ReplaceAllString(m, "${1}"+deployedTag+"${2}")
+	}
+	return masked
+}
@@ -0,0 +1,93 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffContext is the number of unchanged lines around changes in a hunk
+const diffContext = 3
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the unified diff of the file at path from src to dst. It's empty if nothing is changed
+func unifiedDiff(path, src, dst string) []string {
+	var lines []diffLine
+	for _, d := range diff.Do(src, dst) {
+		op := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text != "" {
+				lines = append(lines, diffLine{op: op, text: strings.TrimSuffix(text, "\n")})
+			}
+		}
+	}
+
+	// srcBefore[i] and dstBefore[i] are the numbers of lines before lines[i] in src and dst
+	srcBefore, dstBefore := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	for i, l := range lines {
+		srcBefore[i+1], dstBefore[i+1] = srcBefore[i], dstBefore[i]
+		if l.op != '+' {
+			srcBefore[i+1]++
+		}
+		if l.op != '-' {
+			dstBefore[i+1]++
+		}
+	}
+
+	var result []string
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		// changes close enough to each other share the hunk
+		end := i
+		for j := i; j < len(lines) && j-end <= 2*diffContext; j++ {
+			if lines[j].op != ' ' {
+				end = j
+			}
+		}
+		stop := end + diffContext + 1
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+
+		if result == nil {
+			result = []string{"--- a/" + path, "+++ b/" + path}
+		}
+		result = append(result, fmt.Sprintf("@@ -%s +%s @@", hunkRange(srcBefore[start], srcBefore[stop]), hunkRange(dstBefore[start], dstBefore[stop])))
+		for _, l := range lines[start:stop] {
+			result = append(result, string(l.op)+l.text)
+		}
+		i = stop
+	}
+	return result
+}
+
+func hunkRange(before, after int) string {
+	count := after - before
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, count)
+	}
+}
@@ -0,0 +1,79 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_unifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		dst  string
+		want []string
+	}{
+		{
+			name: "no change",
+			src:  "a\nb\n",
+			dst:  "a\nb\n",
+		},
+		{
+			name: "change in the middle with context",
+			src:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			dst:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: []string{
+				"--- a/k.yaml",
+				"+++ b/k.yaml",
+				"@@ -2,7 +2,7 @@",
+				" 2",
+				" 3",
+				" 4",
+				"-5",
+				"+five",
+				" 6",
+				" 7",
+				" 8",
+			},
+		},
+		{
+			name: "distant changes are in separate hunks",
+			src:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			dst:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\neleven\n",
+			want: []string{
+				"--- a/k.yaml",
+				"+++ b/k.yaml",
+				"@@ -1,4 +1,4 @@",
+				"-1",
+				"+one",
+				" 2",
+				" 3",
+				" 4",
+				"@@ -8,4 +8,4 @@",
+				" 8",
+				" 9",
+				" 10",
+				"-11",
+				"+eleven",
+			},
+		},
+		{
+			name: "added line",
+			src:  "a\n",
+			dst:  "a\nb\n",
+			want: []string{
+				"--- a/k.yaml",
+				"+++ b/k.yaml",
+				"@@ -1 +1,2 @@",
+				" a",
+				"+b",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("k.yaml", tt.src, tt.dst); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mirror-media/major-tom-go/v2/freeze"
+	"github.com/pkg/errors"
+)
+
+// Freeze blocks the changes of a project in a stage until it's unfrozen. texts is interpreted as [project=value, env=value, reason=the rest of texts]. An absent project or env means all
+func Freeze(texts []string, caller string) (messages []string, err error) {
+	if DeployWorker.freezes == nil {
+		return nil, errors.New("freeze is not enabled")
+	}
+
+	texts, reason := popRest(texts, "reason", "=")
+	if reason == "" {
+		return nil, errors.New("reason is expected for the freeze")
+	}
+	texts, project, _ := popValue(texts, "project", "=")
+	texts, stage, _ := popValue(texts, "env", "=")
+
+	if len(texts) != 0 {
+		return nil, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+
+	f := freeze.Freeze{
+		Project: project,
+		Stage:   stage,
+		Reason:  reason,
+		By:      caller,
+	}
+	err = DeployWorker.freezes.Freeze(f)
+	if err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("%s is frozen by %s: %s", f, caller, reason)}, nil
+}
+
+// Unfreeze removes the freeze of a project in a stage. texts is interpreted as [project=value, env=value] of the freeze
+func Unfreeze(texts []string, caller string) (messages []string, err error) {
+	if DeployWorker.freezes == nil {
+		return nil, errors.New("freeze is not enabled")
+	}
+
+	texts, project, _ := popValue(texts, "project", "=")
+	texts, stage, _ := popValue(texts, "env", "=")
+
+	if len(texts) != 0 {
+		return nil, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+
+	f, err := DeployWorker.freezes.Unfreeze(project, stage)
+	if err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("%s is unfrozen by %s, which was frozen by %s: %s", f, caller, f.By, f.Reason)}, nil
+}
+
+// popRest pops the argument and everything after it as its value, so the value can have spaces
+func popRest(texts []string, arg, delimeter string) (newTexts []string, value string) {
+	for i, text := range texts {
+		if cmpArg(text, arg, delimeter) {
+			value = strings.Join(texts[i:], " ")
+			return texts[:i], strings.TrimSpace(strings.TrimPrefix(value, arg+delimeter))
+		}
+	}
+	return texts, ""
+}
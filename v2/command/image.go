@@ -0,0 +1,250 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
+	"github.com/pkg/errors"
+)
+
+// imageTag is the tag to set for an image. An empty image is the image owned by the codebase
+type imageTag struct {
+	image string
+	tag   string
+}
+
+// popImageTags pops the tags of the images, which are image-tag=tag with an optional image=name, or image-tag.name=tag pairs, or a tags=name:tag,name:tag list for more than one image
+func popImageTags(texts []string) (newTexts []string, tags []imageTag, err error) {
+	seen := make(map[string]bool)
+	add := func(image, tag, text string) error {
+		if image == "" || tag == "" {
+			return errors.Errorf("%s should be image-tag.name=tag or tags=name:tag,name:tag", text)
+		}
+		if seen[image] {
+			return errors.Errorf("image(%s) is given more than once", image)
+		}
+		seen[image] = true
+		tags = append(tags, imageTag{image: image, tag: tag})
+		return nil
+	}
+
+	for _, text := range texts {
+		switch {
+		case strings.HasPrefix(text, "image-tag."):
+			pair := strings.SplitN(strings.TrimPrefix(text, "image-tag."), "=", 2)
+			if len(pair) != 2 {
+				return texts, nil, errors.Errorf("%s should be image-tag.name=tag", text)
+			}
+			err = add(pair[0], pair[1], text)
+		case strings.HasPrefix(text, "tags="):
+			for _, item := range strings.Split(strings.TrimPrefix(text, "tags="), ",") {
+				// image names can have a port in the registry, but tags can't have a colon
+				i := strings.LastIndex(item, ":")
+				if i < 0 {
+					err = errors.Errorf("%s should be tags=name:tag,name:tag", text)
+					break
+				}
+				err = add(item[:i], item[i+1:], text)
+				if err != nil {
+					break
+				}
+			}
+		default:
+			newTexts = append(newTexts, text)
+			continue
+		}
+		if err != nil {
+			return texts, nil, err
+		}
+	}
+
+	newTexts, image := popImage(newTexts)
+	newTexts, tag, errPop := popValue(newTexts, "image-tag", "=")
+	switch {
+	case len(tags) == 0 && errPop != nil:
+		return newTexts, nil, errPop
+	case len(tags) == 0:
+		return newTexts, []imageTag{{image: image, tag: tag}}, nil
+	case errPop == nil || image != "":
+		return newTexts, nil, errors.New("image-tag and image can't be used with image-tag.name or tags")
+	}
+	return newTexts, tags, nil
+}
+
+// checkImageTags checks the tags against the tag policy of the codebase in the stage before they are queued
+func checkImageTags(k8sRepo config.KubernetesConfigsRepo, codebase config.Codebase, stage string, tags []imageTag) error {
+	for _, t := range tags {
+		err := k8sRepo.CheckTag(codebase, stage, t.tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyImageTags confirms the tags of the images exist in the registry, so a typo won't be committed. The names of the images are read from the kustomizations at paths
+func verifyImageTags(ctx context.Context, codebase config.Codebase, paths []string, tags []imageTag, message string) error {
+	if DeployWorker.registry == nil || codebase.SkipImageCheck {
+		return nil
+	}
+
+	refs, err := resolveImageTags(ctx, codebase, paths, tags, message)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		exists, err := DeployWorker.registry.HasTag(ctx, ref.image, ref.tag)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("verifying image(%s:%s) in the registry has error", ref.image, ref.tag))
+		}
+		if !exists {
+			return errors.Errorf("image(%s:%s) is not found in the registry", ref.image, ref.tag)
+		}
+	}
+	return nil
+}
+
+// resolveImageTags replaces the images of the tags with the names of the images pulled, which are read from the kustomizations at paths
+func resolveImageTags(ctx context.Context, codebase config.Codebase, paths []string, tags []imageTag, message string) (refs []imageTag, err error) {
+	_, err = ask(ctx, message, func(repo *gitop.Repository) (messages []string, err error) {
+		seen := make(map[imageTag]bool)
+		for _, path := range paths {
+			b, err := repo.ReadFile(path)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("cannot get file(%s)", path))
+			}
+			kustomization, err := loadYAML(path, b)
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range tags {
+				name, err := imageName(kustomization, codebase, t.image)
+				if err != nil {
+					return nil, errors.Wrap(err, path)
+				}
+				ref := imageTag{image: name, tag: t.tag}
+				if !seen[ref] {
+					seen[ref] = true
+					refs = append(refs, ref)
+				}
+			}
+		}
+		return nil, nil
+	})
+	return refs, err
+}
+
+// imageName returns the name of the image pulled for the image in the kustomization, which is newName if it's renamed
+func imageName(kustomization *yamlop.Document, codebase config.Codebase, image string) (string, error) {
+	i, name, err := imageIndex(kustomization, codebase, image)
+	if err != nil {
+		return "", err
+	}
+	if newName, _ := kustomization.Get(fmt.Sprintf("images.%d.newName", i)); newName != "" {
+		return newName, nil
+	}
+	return name, nil
+}
+
+// imageTagEdits sets the tags of the images in the kustomization of the stage
+func imageTagEdits(codebase config.Codebase, stage, project string, tags []imageTag) ([]fileEdit, error) {
+	edits := make([]fileEdit, 0, len(tags))
+	for _, t := range tags {
+		edit, err := imageTagEdit(codebase, stage, project, t.image, t.tag)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, nil
+}
+
+// imageTagEdit sets the newTag of the image in the kustomization of the stage. An empty image is the image owned by the codebase
+func imageTagEdit(codebase config.Codebase, stage, project, image, imageTag string) (fileEdit, error) {
+	path, err := codebase.GetImageKustomizationPath(stage, project)
+	if err != nil {
+		return fileEdit{}, err
+	}
+	return fileEdit{
+		path: path,
+		edit: func(doc *yamlop.Document) (changes []string, err error) {
+			i, name, err := imageIndex(doc, codebase, image)
+			if err != nil {
+				return nil, errors.Wrap(err, path)
+			}
+			tagPath := fmt.Sprintf("images.%d.newTag", i)
+			deployedTag, _ := doc.Get(tagPath)
+			err = doc.Set(tagPath, imageTag)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("fail to set newTag in %s", path))
+			}
+			if deployedTag == "" {
+				return []string{fmt.Sprintf("Set %s(%s) of %s to %v", "image-tag", tagPath, name, imageTag)}, nil
+			}
+			return []string{fmt.Sprintf("Set %s(%s) of %s from %s to %v", "image-tag", tagPath, name, deployedTag, imageTag)}, nil
+		},
+	}, nil
+}
+
+// imageIndex finds the entry of the image in the images of the kustomization by its name. An empty image is the only image owned by the codebase, or the only entry if the codebase doesn't declare its images
+func imageIndex(kustomization *yamlop.Document, codebase config.Codebase, image string) (index int, name string, err error) {
+	if image == "" {
+		switch len(codebase.Images) {
+		case 0:
+		case 1:
+			image = codebase.Images[0]
+		default:
+			return 0, "", errors.Errorf("%s has images(%s), choose one with image=", codebase.Repo, strings.Join(codebase.Images, ", "))
+		}
+	} else if !codebase.HasImage(image) {
+		return 0, "", errors.Errorf("image(%s) is not one of the images(%s) of %s", image, strings.Join(codebase.Images, ", "), codebase.Repo)
+	}
+
+	n, err := kustomization.Len("images")
+	if err != nil {
+		return 0, "", errors.Wrap(err, "kustomization has no images")
+	}
+	names := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		name, err := kustomization.Get(fmt.Sprintf("images.%d.name", i))
+		if err != nil {
+			return 0, "", errors.Wrap(err, fmt.Sprintf("images[%d] has no name", i))
+		}
+		if name == image || (image == "" && n == 1) {
+			return i, name, nil
+		}
+		names = append(names, name)
+	}
+	if image == "" {
+		return 0, "", errors.Errorf("kustomization has images(%s), choose one with image=", strings.Join(names, ", "))
+	}
+	return 0, "", errors.Errorf("image(%s) is not found in the images(%s) of the kustomization", image, strings.Join(names, ", "))
+}
+
+// getImageTag returns the newTag of the image in the kustomization
+func getImageTag(kustomization *yamlop.Document, codebase config.Codebase, image string) (string, error) {
+	i, _, err := imageIndex(kustomization, codebase, image)
+	if err != nil {
+		return "", err
+	}
+	tag, _ := kustomization.Get(fmt.Sprintf("images.%d.newTag", i))
+	return tag, nil
+}
+
+// readImageTag returns the newTag of the image in the kustomization at path in the worktree
+func readImageTag(repo *gitop.Repository, path string, codebase config.Codebase, image string) (string, error) {
+	b, err := repo.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("cannot get file(%s)", path))
+	}
+	kustomization, err := loadYAML(path, b)
+	if err != nil {
+		return "", err
+	}
+	tag, err := getImageTag(kustomization, codebase, image)
+	return tag, errors.Wrap(err, path)
+}
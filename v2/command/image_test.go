@@ -0,0 +1,285 @@
+package command
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
+)
+
+const kustomizationYAML = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+images:
+- name: gcr.io/mirrormedia-1470651750304/openwarehouse
+  newTag: "prod_399440e"
+- name: gcr.io/mirrormedia-1470651750304/cloud-sql-proxy
+  newTag: "1.23"
+`
+
+func Test_imageIndex(t *testing.T) {
+	const (
+		app   = "gcr.io/mirrormedia-1470651750304/openwarehouse"
+		proxy = "gcr.io/mirrormedia-1470651750304/cloud-sql-proxy"
+	)
+	tests := []struct {
+		name      string
+		yaml      string
+		images    []string
+		image     string
+		wantIndex int
+		wantErr   bool
+	}{
+		{
+			name:      "the only image owned by the codebase",
+			yaml:      kustomizationYAML,
+			images:    []string{proxy},
+			wantIndex: 1,
+		},
+		{
+			name:      "image chosen by the command",
+			yaml:      kustomizationYAML,
+			images:    []string{app, proxy},
+			image:     app,
+			wantIndex: 0,
+		},
+		{
+			name:    "more than one image owned by the codebase",
+			yaml:    kustomizationYAML,
+			images:  []string{app, proxy},
+			wantErr: true,
+		},
+		{
+			name:    "image not owned by the codebase",
+			yaml:    kustomizationYAML,
+			images:  []string{app},
+			image:   proxy,
+			wantErr: true,
+		},
+		{
+			name:    "image not in the kustomization",
+			yaml:    kustomizationYAML,
+			images:  []string{"gcr.io/mirrormedia-1470651750304/yt-relay"},
+			wantErr: true,
+		},
+		{
+			name:    "more than one image without images in the codebase",
+			yaml:    kustomizationYAML,
+			wantErr: true,
+		},
+		{
+			name:      "the only image without images in the codebase",
+			yaml:      "images:\n- name: " + app + "\n  newTag: prod_399440e\n",
+			wantIndex: 0,
+		},
+		{
+			name:    "no images",
+			yaml:    "resources:\n- ../base\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := yamlop.Parse([]byte(tt.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := imageIndex(doc, config.Codebase{Repo: "openwarehouse", Images: tt.images}, tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("imageIndex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.wantIndex {
+				t.Errorf("imageIndex() = %v, want %v", got, tt.wantIndex)
+			}
+		})
+	}
+}
+
+const sidecarKustomizationYAML = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+images:
+- name: gcr.io/mirrormedia-1470651750304/openwarehouse
+  newTag: "dev_81ab7ac"
+- name: gcr.io/mirrormedia-1470651750304/openwarehouse-sidecar
+  newTag: "dev_0abc12d"
+`
+
+func Test_imageTagEdit(t *testing.T) {
+	const (
+		app     = "gcr.io/mirrormedia-1470651750304/openwarehouse"
+		sidecar = "gcr.io/mirrormedia-1470651750304/openwarehouse-sidecar"
+	)
+	tests := []struct {
+		name     string
+		yaml     string
+		images   []string
+		image    string
+		want     string
+		wantTags []string
+		wantErr  bool
+	}{
+		{
+			name:     "the image owned by the codebase",
+			yaml:     kustomizationYAML,
+			images:   []string{app},
+			want:     "Set image-tag(images.0.newTag) of " + app + " from prod_399440e to dev_1bac23",
+			wantTags: []string{"dev_1bac23", "1.23"},
+		},
+		{
+			name:     "app chosen by name",
+			yaml:     sidecarKustomizationYAML,
+			images:   []string{app, sidecar},
+			image:    app,
+			want:     "Set image-tag(images.0.newTag) of " + app + " from dev_81ab7ac to dev_1bac23",
+			wantTags: []string{"dev_1bac23", "dev_0abc12d"},
+		},
+		{
+			name:     "sidecar chosen by name",
+			yaml:     sidecarKustomizationYAML,
+			images:   []string{app, sidecar},
+			image:    sidecar,
+			want:     "Set image-tag(images.1.newTag) of " + sidecar + " from dev_0abc12d to dev_1bac23",
+			wantTags: []string{"dev_81ab7ac", "dev_1bac23"},
+		},
+		{
+			name:    "sidecar not chosen",
+			yaml:    sidecarKustomizationYAML,
+			images:  []string{app, sidecar},
+			wantErr: true,
+		},
+		{
+			name:    "no matching entry",
+			yaml:    kustomizationYAML,
+			images:  []string{app, sidecar},
+			image:   sidecar,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codebase := config.Codebase{
+				Images: tt.images,
+				Repo:   "openwarehouse",
+				Stages: []string{"dev"},
+				Type:   1,
+			}
+			e, err := imageTagEdit(codebase, "dev", "", tt.image, "dev_1bac23")
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err := yamlop.Parse([]byte(tt.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes, err := e.edit(doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fileEdit.edit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(changes) != 1 || changes[0] != tt.want {
+				t.Errorf("fileEdit.edit() = %v, want %v", changes, tt.want)
+			}
+			for i, want := range tt.wantTags {
+				if got, _ := doc.Get(fmt.Sprintf("images.%d.newTag", i)); got != want {
+					t.Errorf("images.%d.newTag = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_popImageTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		texts     []string
+		wantTexts []string
+		wantTags  []imageTag
+		wantErr   bool
+	}{
+		{
+			name:      "image-tag only",
+			texts:     []string{"image-tag=prod_399440e", "dry-run=true"},
+			wantTexts: []string{"dry-run=true"},
+			wantTags:  []imageTag{{tag: "prod_399440e"}},
+		},
+		{
+			name:     "image-tag with image",
+			texts:    []string{"image=gcr.io/mirrormedia-1470651750304/openwarehouse", "image-tag=prod_399440e"},
+			wantTags: []imageTag{{image: "gcr.io/mirrormedia-1470651750304/openwarehouse", tag: "prod_399440e"}},
+		},
+		{
+			name:  "image-tag pairs",
+			texts: []string{"image-tag.openwarehouse=prod_399440e", "image-tag.openwarehouse-migration=prod_399440e"},
+			wantTags: []imageTag{
+				{image: "openwarehouse", tag: "prod_399440e"},
+				{image: "openwarehouse-migration", tag: "prod_399440e"},
+			},
+		},
+		{
+			name:  "tags list with a registry port",
+			texts: []string{"tags=localhost:5000/openwarehouse:dev_1bac23,worker:dev_1bac23"},
+			wantTags: []imageTag{
+				{image: "localhost:5000/openwarehouse", tag: "dev_1bac23"},
+				{image: "worker", tag: "dev_1bac23"},
+			},
+		},
+		{
+			name:    "image given more than once",
+			texts:   []string{"image-tag.worker=dev_1bac23", "tags=worker:dev_2bac23"},
+			wantErr: true,
+		},
+		{
+			name:    "image-tag with pairs",
+			texts:   []string{"image-tag=dev_1bac23", "image-tag.worker=dev_1bac23"},
+			wantErr: true,
+		},
+		{
+			name:    "tags without a tag",
+			texts:   []string{"tags=worker"},
+			wantErr: true,
+		},
+		{
+			name:    "no image-tag",
+			texts:   []string{"dry-run=true"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTexts, gotTags, err := popImageTags(tt.texts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("popImageTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(gotTexts) != 0 || len(tt.wantTexts) != 0 {
+				if !reflect.DeepEqual(gotTexts, tt.wantTexts) {
+					t.Errorf("popImageTags() texts = %v, want %v", gotTexts, tt.wantTexts)
+				}
+			}
+			if !reflect.DeepEqual(gotTags, tt.wantTags) {
+				t.Errorf("popImageTags() tags = %v, want %v", gotTags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func Test_imageName(t *testing.T) {
+	doc, err := yamlop.Parse([]byte("images:\n- name: openwarehouse\n  newName: gcr.io/mirrormedia-1470651750304/openwarehouse\n- name: gcr.io/mirrormedia-1470651750304/cloud-sql-proxy\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codebase := config.Codebase{Repo: "openwarehouse"}
+	for image, want := range map[string]string{
+		"openwarehouse": "gcr.io/mirrormedia-1470651750304/openwarehouse",
+		"gcr.io/mirrormedia-1470651750304/cloud-sql-proxy": "gcr.io/mirrormedia-1470651750304/cloud-sql-proxy",
+	} {
+		if got, err := imageName(doc, codebase, image); err != nil || got != want {
+			t.Errorf("imageName(%s) = %v, %v, want %v", image, got, err, want)
+		}
+	}
+}
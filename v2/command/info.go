@@ -1,29 +1,240 @@
 package command
 
-// Info provides infomation of service. textParts in interpreted as [project, stage, service]
-// func Info(ctx context.Context, clusterConfigs config.K8S, textParts []string) (messages []string, err error) {
-// 	switch len(textParts) {
-// 	// Info does not provice information for projects and stages
-// 	case 0, 1, 2:
-// 		messages = []string{"call help"}
-// 		err = errors.New("Please provide more details...")
-// 	// service
-// 	case 3:
-// 		kubeConfigPath, errConfig := k8sop.SwitchKubeConfig(clusterConfigs, textParts[0], textParts[1])
-// 		if errConfig != nil {
-// 			messages = []string{"call help"}
-// 			err = errConfig
-// 			break
-// 		}
-
-// 		info, errGet := k8sop.GetDeploymentInfo(ctx, kubeConfigPath, textParts[2])
-// 		if errGet != nil {
-// 			err = errGet
-// 			messages = []string{"call list"}
-// 			break
-// 		}
-// 		messages = []string{fmt.Sprintf("%s\n\tImageTag: %s\n\tAvailable pods: %d\n\tReady pods: %d\n\tUpdated pods: %d", textParts[2], info.ImageTag, info.Available, info.Ready, info.Updated)}
-// 	}
-
-// 	return messages, err
-// }
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"github.com/pkg/errors"
+)
+
+// desiredState is the state of a service defined in kubernetes-configs
+type desiredState struct {
+	imageTag    string
+	hasHPA      bool
+	maxReplicas int
+	minReplicas int
+	// commit is the head of kubernetes-configs, which the GitOps engine should have applied
+	commit string
+}
+
+// liveState is the state of a service on Kubernetes
+type liveState struct {
+	deployment k8sop.DeploymentInfo
+	// hpa is nil if the service has no HorizontalPodAutoscaler
+	hpa *k8sop.HPAInfo
+	// gitOps is the name of the engine syncing kubernetes-configs to the cluster
+	gitOps string
+	// sync is how the GitOps engine syncs kubernetes-configs, which is nil with syncErr if it's unavailable
+	sync    *k8sop.SyncStatus
+	syncErr error
+}
+
+// Info reports the desired state in kubernetes-configs and the live state on Kubernetes of a service, or all services of a repo. texts is interpreted as [service|repo, env=value, project=value, image=name]
+func Info(ctx context.Context, clusters *k8sop.ClusterResolver, k8sRepo config.KubernetesConfigsRepo, texts []string, message string) (messages []string, err error) {
+	if !DeployWorker.isRunning {
+		return nil, errors.New("deploy worker is not running")
+	}
+
+	if len(texts) < 1 {
+		return nil, errors.New("call help")
+	}
+
+	name, texts := pop(texts, 0)
+	codebase, services, err := resolveServices(k8sRepo.Configs, name)
+	if err != nil {
+		return nil, err
+	}
+
+	texts, stage, err := popValue(texts, "env", "=")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting env for info encountered an error")
+	}
+
+	// project is optional because it can be implied by the service in most cases
+	texts, project, _ := popValue(texts, "project", "=")
+	texts, image := popImage(texts)
+
+	if len(texts) != 0 {
+		return nil, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+
+	for _, service := range services {
+		if project != "" && service.Project != "" && service.Project != project {
+			continue
+		}
+		serviceProject, err := resolveProject(*codebase, service, project)
+		if err != nil {
+			return messages, err
+		}
+		m, err := info(ctx, clusters, *codebase, service, stage, serviceProject, image, message)
+		messages = append(messages, m...)
+		if err != nil {
+			return messages, err
+		}
+	}
+	if len(messages) == 0 {
+		return nil, errors.Errorf("%s has no service for project(%s)", name, project)
+	}
+	return messages, nil
+}
+
+// resolveProject returns the project of the service. Services of type 1 codebases have no project so it has to be supplied unless the codebase has only one project
+func resolveProject(codebase config.Codebase, service config.Service, project string) (string, error) {
+	switch {
+	case service.Project != "":
+		return service.Project, nil
+	case project != "":
+		if !codebase.HasProject(project) {
+			return "", errors.Errorf("project(%s) is not supported for %s", project, codebase.Repo)
+		}
+		return project, nil
+	case len(codebase.Projects) == 1:
+		return codebase.Projects[0], nil
+	default:
+		return "", errors.Errorf("argument(project) is expected for %s", service.Name)
+	}
+}
+
+func info(ctx context.Context, clusters *k8sop.ClusterResolver, codebase config.Codebase, service config.Service, stage, project, image, message string) (messages []string, err error) {
+	kustomizationPath, err := codebase.GetImageKustomizationPath(stage, project)
+	if err != nil {
+		return nil, err
+	}
+	hpaPath, err := codebase.GetHpaPath(stage, project, service.SimpleService)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := clusters.Resolve(codebase.Repo, project, stage)
+	if err != nil {
+		return nil, err
+	}
+
+	var desired desiredState
+	_, err = ask(ctx, message, func(repo *gitop.Repository) (messages []string, err error) {
+		desired, err = getDesiredState(repo, codebase, image, kustomizationPath, hpaPath)
+		if err != nil {
+			return nil, err
+		}
+		head, err := repo.GetHeadHash()
+		if err == nil {
+			desired.commit = head.String()
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var live liveState
+	live.deployment, err = cluster.GetDeploymentInfo(ctx, service.Name)
+	if err != nil {
+		return formatInfo(service.Name, stage, desired, nil), errors.Wrap(err, fmt.Sprintf("getting deployment of %s has error", service.Name))
+	}
+	if desired.hasHPA {
+		hpa, err := cluster.GetHPAInfo(ctx, service.Name)
+		if err != nil {
+			return formatInfo(service.Name, stage, desired, nil), errors.Wrap(err, fmt.Sprintf("getting hpa of %s has error", service.Name))
+		}
+		live.hpa = &hpa
+	}
+	// clusters without their GitOps engine have the other states still
+	live.gitOps = cluster.GitOpsName()
+	sync, err := cluster.GetSyncStatus(ctx, kustomizationPath)
+	if err != nil {
+		live.syncErr = err
+	} else {
+		live.sync = &sync
+	}
+
+	return formatInfo(service.Name, stage, desired, &live), nil
+}
+
+// getDesiredState reads the image tag and the autoscaling configurations from kubernetes-configs
+func getDesiredState(repo *gitop.Repository, codebase config.Codebase, image, kustomizationPath, hpaPath string) (desired desiredState, err error) {
+	desired.imageTag, err = readImageTag(repo, kustomizationPath, codebase, image)
+	if err != nil {
+		return desired, err
+	}
+
+	desired.hasHPA, desired.maxReplicas, desired.minReplicas, err = readReplicas(repo, hpaPath)
+	return desired, err
+}
+
+// readReplicas reads the autoscaling configurations in the worktree. hasHPA is false if the file doesn't exist
+func readReplicas(repo *gitop.Repository, hpaPath string) (hasHPA bool, maxReplicas, minReplicas int, err error) {
+	b, err := repo.ReadFile(hpaPath)
+	if os.IsNotExist(err) {
+		return false, 0, 0, nil
+	} else if err != nil {
+		return false, 0, 0, errors.Wrap(err, fmt.Sprintf("cannot get file(%s)", hpaPath))
+	}
+	hpa, err := loadYAML(hpaPath, b)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	// minReplicas defaults to 1 in Kubernetes
+	return true, getInt(hpa, maxReplicasKey, 0), getInt(hpa, minReplicasKey, 1), nil
+}
+
+// formatInfo puts the desired state and the live state side by side and flags the drift between them. live is nil if it's unavailable
+func formatInfo(name, stage string, desired desiredState, live *liveState) []string {
+	drift := func(isDrifted bool) string {
+		if isDrifted {
+			return " (drift)"
+		}
+		return ""
+	}
+
+	if live == nil {
+		messages := []string{fmt.Sprintf("%s(%s): live state is unavailable", name, stage), fmt.Sprintf("\tImageTag: desired %s", desired.imageTag)}
+		if desired.hasHPA {
+			messages = append(messages, fmt.Sprintf("\tMinReplicas: desired %d", desired.minReplicas), fmt.Sprintf("\tMaxReplicas: desired %d", desired.maxReplicas))
+		}
+		return messages
+	}
+
+	messages := []string{
+		fmt.Sprintf("%s(%s)", name, stage),
+		fmt.Sprintf("\tImageTag: desired %s, live %s%s", desired.imageTag, live.deployment.ImageTag, drift(desired.imageTag != live.deployment.ImageTag)),
+	}
+	switch {
+	case desired.hasHPA && live.hpa != nil:
+		messages = append(messages,
+			fmt.Sprintf("\tMinReplicas: desired %d, live %d%s", desired.minReplicas, live.hpa.MinReplicas, drift(desired.minReplicas != int(live.hpa.MinReplicas))),
+			fmt.Sprintf("\tMaxReplicas: desired %d, live %d%s", desired.maxReplicas, live.hpa.MaxReplicas, drift(desired.maxReplicas != int(live.hpa.MaxReplicas))),
+			fmt.Sprintf("\tCurrent replicas: %d", live.hpa.Current),
+		)
+	default:
+		messages = append(messages, "\tAutoscaling is not configured", fmt.Sprintf("\tReplicas: %d", live.deployment.Replicas))
+	}
+	messages = append(messages,
+		fmt.Sprintf("\tAvailable pods: %d", live.deployment.Available),
+		fmt.Sprintf("\tReady pods: %d", live.deployment.Ready),
+		fmt.Sprintf("\tUpdated pods: %d", live.deployment.Updated),
+	)
+	switch {
+	case live.sync != nil:
+		sync := live.sync
+		messages = append(messages,
+			fmt.Sprintf("\t%s: desired %s, applied %s%s", live.gitOps, shortHash(desired.commit), sync.AppliedRevision, drift(desired.commit != "" && !sync.HasApplied(desired.commit))),
+			fmt.Sprintf("\t\t%s: %s", sync.Resource, sync.Ready),
+		)
+		if sync.Suspended {
+			messages = append(messages, fmt.Sprintf("\t\t%s is suspended", sync.Resource))
+		}
+		if sync.Drifted {
+			messages = append(messages, fmt.Sprintf("\t\t%s is out of sync", sync.Resource))
+		}
+		if sync.Source != "" {
+			messages = append(messages, fmt.Sprintf("\t\t%s: fetched %s, %s", sync.Source, sync.SourceRevision, sync.SourceReady))
+		}
+	case live.syncErr != nil:
+		messages = append(messages, fmt.Sprintf("\t%s: unavailable, %s", live.gitOps, live.syncErr))
+	}
+	return messages
+}
@@ -1 +1,198 @@
 package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+)
+
+func Test_resolveProject(t *testing.T) {
+	tests := []struct {
+		name     string
+		codebase config.Codebase
+		service  config.Service
+		project  string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "type 2 service has its project",
+			codebase: config.Codebase{Type: 2, Repo: "openwarehouse", Projects: []string{"tv", "readr"}},
+			service:  config.Service{Name: "openwarehouse-tv-cms", Project: "tv"},
+			want:     "tv",
+		},
+		{
+			name:     "type 1 service with the only project",
+			codebase: config.Codebase{Type: 1, Repo: "mirror-tv-nuxt", Projects: []string{"tv"}},
+			service:  config.Service{Name: "mirror-tv-nuxt"},
+			want:     "tv",
+		},
+		{
+			name:     "type 1 service with the supplied project",
+			codebase: config.Codebase{Type: 1, Repo: "mirror-tv-nuxt"},
+			service:  config.Service{Name: "mirror-tv-nuxt"},
+			project:  "tv",
+			want:     "tv",
+		},
+		{
+			name:     "type 1 service with an unsupported project",
+			codebase: config.Codebase{Type: 1, Repo: "mirror-tv-nuxt", Projects: []string{"tv"}},
+			service:  config.Service{Name: "mirror-tv-nuxt"},
+			project:  "readr",
+			wantErr:  true,
+		},
+		{
+			name:     "type 1 service without project",
+			codebase: config.Codebase{Type: 1, Repo: "mirror-tv-nuxt"},
+			service:  config.Service{Name: "mirror-tv-nuxt"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveProject(tt.codebase, tt.service, tt.project)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveProject() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("resolveProject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_formatInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired desiredState
+		live    *liveState
+		want    []string
+	}{
+		{
+			name:    "no drift",
+			desired: desiredState{imageTag: "prod_81ab7ac", hasHPA: true, maxReplicas: 4, minReplicas: 2},
+			live: &liveState{
+				deployment: k8sop.DeploymentInfo{Available: 3, ImageTag: "prod_81ab7ac", Ready: 3, Replicas: 3, Updated: 3},
+				hpa:        &k8sop.HPAInfo{Current: 3, Desired: 3, MaxReplicas: 4, MinReplicas: 2},
+			},
+			want: []string{
+				"svc(prod)",
+				"\tImageTag: desired prod_81ab7ac, live prod_81ab7ac",
+				"\tMinReplicas: desired 2, live 2",
+				"\tMaxReplicas: desired 4, live 4",
+				"\tCurrent replicas: 3",
+				"\tAvailable pods: 3",
+				"\tReady pods: 3",
+				"\tUpdated pods: 3",
+			},
+		},
+		{
+			name:    "drift",
+			desired: desiredState{imageTag: "prod_399440e", hasHPA: true, maxReplicas: 6, minReplicas: 2},
+			live: &liveState{
+				deployment: k8sop.DeploymentInfo{Available: 3, ImageTag: "prod_81ab7ac", Ready: 3, Replicas: 3, Updated: 1},
+				hpa:        &k8sop.HPAInfo{Current: 3, Desired: 3, MaxReplicas: 4, MinReplicas: 2},
+			},
+			want: []string{
+				"svc(prod)",
+				"\tImageTag: desired prod_399440e, live prod_81ab7ac (drift)",
+				"\tMinReplicas: desired 2, live 2",
+				"\tMaxReplicas: desired 6, live 4 (drift)",
+				"\tCurrent replicas: 3",
+				"\tAvailable pods: 3",
+				"\tReady pods: 3",
+				"\tUpdated pods: 1",
+			},
+		},
+		{
+			name:    "without autoscaling",
+			desired: desiredState{imageTag: "prod_81ab7ac"},
+			live: &liveState{
+				deployment: k8sop.DeploymentInfo{Available: 1, ImageTag: "prod_81ab7ac", Ready: 1, Replicas: 1, Updated: 1},
+			},
+			want: []string{
+				"svc(prod)",
+				"\tImageTag: desired prod_81ab7ac, live prod_81ab7ac",
+				"\tAutoscaling is not configured",
+				"\tReplicas: 1",
+				"\tAvailable pods: 1",
+				"\tReady pods: 1",
+				"\tUpdated pods: 1",
+			},
+		},
+		{
+			name:    "flux behind kubernetes-configs",
+			desired: desiredState{imageTag: "prod_399440e", commit: "1bac23f9c0"},
+			live: &liveState{
+				deployment: k8sop.DeploymentInfo{Available: 1, ImageTag: "prod_81ab7ac", Ready: 1, Replicas: 1, Updated: 1},
+				gitOps:     "Flux",
+				sync: &k8sop.SyncStatus{
+					Resource:        "Kustomization flux-system/openwarehouse",
+					Ready:           k8sop.Condition{Status: "False", Reason: "BuildFailed", Message: "kustomize build failed"},
+					AppliedRevision: "main@sha1:0abc12d8e1",
+					Source:          "GitRepository flux-system/kubernetes-configs",
+					SourceReady:     k8sop.Condition{Status: "True", Reason: "Succeeded"},
+					SourceRevision:  "main@sha1:1bac23f9c0",
+				},
+			},
+			want: []string{
+				"svc(prod)",
+				"\tImageTag: desired prod_399440e, live prod_81ab7ac (drift)",
+				"\tAutoscaling is not configured",
+				"\tReplicas: 1",
+				"\tAvailable pods: 1",
+				"\tReady pods: 1",
+				"\tUpdated pods: 1",
+				"\tFlux: desired 1bac23f, applied main@sha1:0abc12d8e1 (drift)",
+				"\t\tKustomization flux-system/openwarehouse: Ready False BuildFailed: kustomize build failed",
+				"\t\tGitRepository flux-system/kubernetes-configs: fetched main@sha1:1bac23f9c0, Ready True Succeeded",
+			},
+		},
+		{
+			name:    "argo cd out of sync",
+			desired: desiredState{imageTag: "prod_81ab7ac", commit: "1bac23f9c0"},
+			live: &liveState{
+				deployment: k8sop.DeploymentInfo{Available: 1, ImageTag: "prod_81ab7ac", Ready: 1, Replicas: 1, Updated: 1},
+				gitOps:     "Argo CD",
+				sync: &k8sop.SyncStatus{
+					Resource:        "Application argocd/openwarehouse",
+					Drifted:         true,
+					Ready:           k8sop.Condition{Status: "True", Reason: "Healthy"},
+					AppliedRevision: "1bac23f9c0",
+				},
+			},
+			want: []string{
+				"svc(prod)",
+				"\tImageTag: desired prod_81ab7ac, live prod_81ab7ac",
+				"\tAutoscaling is not configured",
+				"\tReplicas: 1",
+				"\tAvailable pods: 1",
+				"\tReady pods: 1",
+				"\tUpdated pods: 1",
+				"\tArgo CD: desired 1bac23f, applied 1bac23f9c0",
+				"\t\tApplication argocd/openwarehouse: Ready True Healthy",
+				"\t\tApplication argocd/openwarehouse is out of sync",
+			},
+		},
+		{
+			name:    "live state is unavailable",
+			desired: desiredState{imageTag: "prod_81ab7ac", hasHPA: true, maxReplicas: 4, minReplicas: 2},
+			want: []string{
+				"svc(prod): live state is unavailable",
+				"\tImageTag: desired prod_81ab7ac",
+				"\tMinReplicas: desired 2",
+				"\tMaxReplicas: desired 4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatInfo("svc", "prod", tt.desired, tt.live); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("formatInfo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
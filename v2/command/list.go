@@ -1,78 +1,146 @@
 package command
 
-// TODO
-// // List provide infomation about cluster, stages, and services. It should also provide helm message if input is invalid
-// func List(ctx context.Context, clusterConfigs config.K8S, textParts []string) (messages []string, err error) {
-// 	switch len(textParts) {
-// 	case 0:
-// 		var projects []string
-// 		for key := range clusterConfigs {
-// 			projects = append(projects, string(key))
-// 		}
-// 		sort.Strings(projects)
-// 		messages = []string{
-// 			"The following projects are available: " + strings.Join(projects, ", "),
-// 		}
-
-// 	case 1:
-// 		// List stages
-// 		project := textParts[0]
-// 		stageConfigs, isExisting := clusterConfigs[config.Project(project)]
-// 		if !isExisting {
-// 			// TODO call help
-// 			return []string{"call help"}, errors.Errorf("project(%s) doesn't exist", project)
-// 		}
-// 		stages := make([]string, 0, len(stageConfigs))
-// 		for k := range stageConfigs {
-// 			stages = append(stages, string(k))
-// 		}
-// 		sort.Strings(stages)
-// 		messages = []string{
-// 			fmt.Sprintf("The following stages are available for %s: %s", project, strings.Join(stages, ", ")),
-// 		}
-// 	case 2:
-// 		kubeConfigPath, err := k8sop.SwitchKubeConfig(clusterConfigs, textParts[0], textParts[1])
-// 		if err != nil {
-// 			return nil, err
-// 		}
-// 		releases, err := k8sop.ListReleases(ctx, kubeConfigPath)
-// 		if err != nil {
-// 			return nil, err
-// 		}
-
-// 		messages = make([]string, len(releases))
-// 		for i, release := range releases {
-// 			messages[i] = fmt.Sprintf("%s: %s", release.Name, release.Status)
-// 		}
-// 	case 3:
-// 		// kubeConfigPath, err := k8sop.SwitchKubeConfig(clusterConfigs, textParts[0], textParts[1])
-// 		// if err != nil {
-// 		// 	return nil, err
-// 		// }
-// 		// // var namespace string
-// 		// if textParts[2] == "cronjobs" {
-// 		// 	namespace = "cron"
-// 		// } else {
-// 		// 	namespace = "default"
-// 		// }
-// 		// TODO
-// 		messages = append(messages, "history is not implemented yet")
-// 		// versions, err := k8sop.ListHelmReleaseVersion(kubeConfigPath, textParts[2], namespace, 11)
-// 		// if err != nil {
-// 		// 	return nil, err
-// 		// }
-// 		// for _, version := range versions {
-// 		// 	messages = append(messages, fmt.Sprintf("%s(%d)", textParts[2], version.Version))
-// 		// 	messages = append(messages, fmt.Sprintf("\t%s: %v", "ImageTag", version.ImageTag))
-// 		// 	messages = append(messages, fmt.Sprintf("\t%s: %v", "Pods", version.ReplicaCount))
-// 		// 	messages = append(messages, fmt.Sprintf("\t%s", "AutoScaling"))
-// 		// 	messages = append(messages, fmt.Sprintf("\t\t%s: %v", "Enabled", version.AutoScaling.Enabled))
-// 		// 	messages = append(messages, fmt.Sprintf("\t\t%s: %v", "Max Pods", version.AutoScaling.MaxReplicas))
-// 		// 	messages = append(messages, fmt.Sprintf("\t\t%s: %v", "Min Pods", version.AutoScaling.MinReplicas))
-// 		// }
-// 	default:
-// 		err = errors.Errorf("What is going on now?")
-// 	}
-
-// 	return messages, err
-// }
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+	"github.com/pkg/errors"
+)
+
+// historySize is the number of changes listed for a service
+const historySize = 11
+
+// List provides the supported services in a stage of a project or the history of a service. texts is interpreted as [project=value, env=value] or [service|repo, env=value]
+func List(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []string, message string) (messages []string, err error) {
+	if len(texts) < 1 {
+		return nil, errors.New("call help")
+	}
+
+	if cmpArg(texts[0], "project", "=") {
+		return listServices(k8sRepo.Configs, texts)
+	}
+
+	if !DeployWorker.isRunning {
+		return nil, errors.New("deploy worker is not running")
+	}
+
+	name, texts := pop(texts, 0)
+
+	texts, stage, err := popValue(texts, "env", "=")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting env for list encountered an error")
+	}
+
+	if len(texts) != 0 {
+		return nil, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+
+	paths, err := getPaths(k8sRepo.Configs, name, stage)
+	if err != nil {
+		return nil, err
+	}
+
+	return ask(ctx, message, func(repo *gitop.Repository) (messages []string, err error) {
+		commits, err := repo.Log(historySize, paths...)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("getting history of %s has error", name))
+		}
+		if len(commits) == 0 {
+			return []string{fmt.Sprintf("%s has no change in %s", name, stage)}, nil
+		}
+		for i, c := range commits {
+			summary := strings.SplitN(c.Message, "\n", 2)[0]
+			messages = append(messages, fmt.Sprintf("%d. `%s` %s %s (%s)", i+1, c.Hash.String()[:7], c.Author.When.Format("2006-01-02 15:04"), summary, c.Author.Name))
+		}
+		return messages, nil
+	})
+}
+
+// listServices lists {repo}/{service} for services of type 2 codebases and {repo} for type 1 codebases
+func listServices(codebases []config.Codebase, texts []string) (messages []string, err error) {
+	texts, project, err := popValue(texts, "project", "=")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting project for list encountered an error")
+	}
+
+	texts, stage, err := popValue(texts, "env", "=")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting env for list encountered an error")
+	}
+
+	if len(texts) != 0 {
+		return nil, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+
+	var names []string
+	for _, c := range codebases {
+		if !c.HasStage(stage) || !c.HasProject(project) {
+			continue
+		}
+		services, err := c.GetServices()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("getting services of %s has error", c.Repo))
+		}
+		for _, s := range services {
+			switch {
+			case c.Type == 1:
+				names = append(names, s.Repo)
+			case s.Project == project:
+				names = append(names, fmt.Sprintf("%s/%s", s.Repo, s.SimpleService))
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.Errorf("no service is available for project(%s) in stage(%s)", project, stage)
+	}
+	for i, name := range names {
+		messages = append(messages, fmt.Sprintf("%d. `%s`", i+1, name))
+	}
+	return messages, nil
+}
+
+// getPaths returns the files in kubernetes-configs defining the service, or all services of the repo, in the stage
+func getPaths(codebases []config.Codebase, name, stage string) (paths []string, err error) {
+	codebase, services, err := resolveServices(codebases, name)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	for _, s := range services {
+		kustomizationPath, err := codebase.GetImageKustomizationPath(stage, s.Project)
+		if err != nil {
+			return nil, err
+		}
+		hpaPath, err := codebase.GetHpaPath(stage, s.Project, s.SimpleService)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range []string{kustomizationPath, hpaPath} {
+			if !found[path] {
+				found[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// resolveServices resolves the name to the service or all services of the repo
+func resolveServices(codebases []config.Codebase, name string) (*config.Codebase, []config.Service, error) {
+	codebase, service, err := findService(codebases, name)
+	if err == nil {
+		return codebase, []config.Service{*service}, nil
+	}
+	for i := range codebases {
+		if codebases[i].Repo == name {
+			services, err := codebases[i].GetServices()
+			return &codebases[i], services, err
+		}
+	}
+	return nil, nil, errors.Errorf("%s is neither a service nor a repo", name)
+}
@@ -1 +1,136 @@
 package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/internal/test"
+)
+
+func Test_listServices(t *testing.T) {
+	tests := []struct {
+		name         string
+		texts        []string
+		wantMessages []string
+		wantErr      bool
+	}{
+		{
+			name:  "list tv in staging",
+			texts: []string{"project=tv", "env=staging"},
+			wantMessages: []string{
+				"1. `openwarehouse/cms`",
+				"2. `openwarehouse/gql-external`",
+				"3. `openwarehouse/gql-internal`",
+				"4. `mirror-tv-nuxt`",
+			},
+		},
+		{
+			name:  "arguments in any order",
+			texts: []string{"env=prod", "project=tv"},
+			wantMessages: []string{
+				"1. `openwarehouse/cms`",
+				"2. `openwarehouse/gql-external`",
+				"3. `openwarehouse/gql-internal`",
+				"4. `mirror-tv-nuxt`",
+			},
+		},
+		{
+			name:  "only type 1 codebases are shared by other projects",
+			texts: []string{"project=readr", "env=prod"},
+			wantMessages: []string{
+				"1. `mirror-tv-nuxt`",
+			},
+		},
+		{
+			name:    "unknown stage",
+			texts:   []string{"project=tv", "env=qa"},
+			wantErr: true,
+		},
+		{
+			name:    "env is required",
+			texts:   []string{"project=tv"},
+			wantErr: true,
+		},
+		{
+			name:    "extra argument",
+			texts:   []string{"project=tv", "env=dev", "image-tag=abc"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMessages, err := listServices(test.K8sRepo.Configs, tt.texts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("listServices() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotMessages, tt.wantMessages) {
+				t.Errorf("listServices() = %v, want %v", gotMessages, tt.wantMessages)
+			}
+		})
+	}
+}
+
+func Test_getPaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   string
+		stage     string
+		wantPaths []string
+		wantErr   bool
+	}{
+		{
+			name:    "type 2 service",
+			service: "openwarehouse-tv-cms",
+			stage:   "prod",
+			wantPaths: []string{
+				"openwarehouse/overlays/prod/overlays/tv/base/kustomization.yaml",
+				"openwarehouse/overlays/prod/overlays/tv/overlays/cms/hpa.yaml",
+			},
+		},
+		{
+			name:    "type 2 repo has all services and the shared kustomization only once",
+			service: "openwarehouse",
+			stage:   "dev",
+			wantPaths: []string{
+				"openwarehouse/overlays/dev/base/kustomization.yaml",
+				"openwarehouse/overlays/dev/overlays/tv/overlays/cms/hpa.yaml",
+				"openwarehouse/overlays/dev/overlays/tv/overlays/gql-external/hpa.yaml",
+				"openwarehouse/overlays/dev/overlays/tv/overlays/gql-internal/hpa.yaml",
+			},
+		},
+		{
+			name:    "type 1 repo",
+			service: "mirror-tv-nuxt",
+			stage:   "staging",
+			wantPaths: []string{
+				"mirror-tv-nuxt/overlays/staging/kustomization.yaml",
+				"mirror-tv-nuxt/overlays/staging/hpa.yaml",
+			},
+		},
+		{
+			name:    "unknown name",
+			service: "mirror-tv-vue",
+			stage:   "staging",
+			wantErr: true,
+		},
+		{
+			name:    "unknown stage",
+			service: "mirror-tv-nuxt",
+			stage:   "qa",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPaths, err := getPaths(test.K8sRepo.Configs, tt.service, tt.stage)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getPaths() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotPaths, tt.wantPaths) {
+				t.Errorf("getPaths() = %v, want %v", gotPaths, tt.wantPaths)
+			}
+		})
+	}
+}
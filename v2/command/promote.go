@@ -0,0 +1,198 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
+	"github.com/pkg/errors"
+)
+
+// PromotePlan describes the promotion, which is confirmed before it changes prod
+type PromotePlan struct {
+	Repo    string
+	Project string
+	From    string
+	To      string
+	// Image is empty for the image owned by the repo
+	Image  string
+	DryRun bool
+}
+
+type promoteArgs struct {
+	codebase *config.Codebase
+	from     string
+	to       string
+	project  string
+	image    string
+	dryRun   bool
+	override bool
+	// force promotes a tag to prod which was never on staging
+	force bool
+}
+
+// Promote moves the image tag of a repo from a stage to another. texts is interpreted as [repo, from=value, to=value, project=value, image=name, force=true]
+func Promote(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []string, message, caller string) (messages []string, err error) {
+	if !DeployWorker.isRunning {
+		return nil, errors.New("deploy worker is not running")
+	}
+
+	args, err := parsePromote(k8sRepo, texts)
+	if err != nil {
+		return nil, err
+	}
+	codebase, from, to, project := args.codebase, args.from, args.to, args.project
+
+	// the paths are resolved again by the plan, but a wrong stage or project is told before queueing
+	_, err = codebase.GetImageKustomizationPath(from, project)
+	if err != nil {
+		return nil, err
+	}
+	_, err = codebase.GetImageKustomizationPath(to, project)
+	if err != nil {
+		return nil, err
+	}
+
+	var pendingProject string
+	if project != "" && to == "prod" && codebase.Type == 2 {
+		pendingProject = "/" + project
+	}
+
+	return queue(ctx, Deployment{
+		codebase: codebase,
+		project:  project,
+		stage:    to,
+		caller:   caller,
+		message:  message,
+		dryRun:   args.dryRun,
+		override: args.override,
+		title:    fmt.Sprintf("promote(%s/%s%s): promoted from %s by %s", codebase.Repo, to, pendingProject, from, caller),
+		plan: func(repo *gitop.Repository) ([]fileEdit, error) {
+			return planPromotion(repo, k8sRepo, args)
+		},
+	})
+}
+
+// planPromotion reads the image tag of the source stage from kubernetes-configs and sets it in the target stage
+func planPromotion(repo *gitop.Repository, k8sRepo config.KubernetesConfigsRepo, args promoteArgs) ([]fileEdit, error) {
+	codebase, from, to, project, image := args.codebase, args.from, args.to, args.project, args.image
+	sourcePath, err := codebase.GetImageKustomizationPath(from, project)
+	if err != nil {
+		return nil, err
+	}
+	targetPath, err := codebase.GetImageKustomizationPath(to, project)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceTag, err := readImageTag(repo, sourcePath, *codebase, image)
+	if err != nil {
+		return nil, err
+	}
+	if sourceTag == "" {
+		return nil, errors.Errorf("%s has no image tag in %s", codebase.Repo, from)
+	}
+	// the tag policy of the target stage applies to the promoted tag as it does to a deployed one
+	err = checkImageTags(k8sRepo, *codebase, to, []imageTag{{image: image, tag: sourceTag}})
+	if err != nil {
+		return nil, err
+	}
+	// a promotion to prod is a release, which only ships the tags that have been on staging
+	if to == "prod" {
+		err = checkStaged(repo, releaseArgs{
+			codebase:  codebase,
+			projects:  []string{project},
+			imageTags: []imageTag{{image: image, tag: sourceTag}},
+			force:     args.force,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	deployedTag, err := readImageTag(repo, targetPath, *codebase, image)
+	if err != nil {
+		return nil, err
+	}
+	if sourceTag == deployedTag {
+		return nil, errors.Errorf("%s is deployed in %s already", sourceTag, to)
+	}
+
+	edit, err := imageTagEdit(*codebase, to, project, image, sourceTag)
+	if err != nil {
+		return nil, err
+	}
+	setTag := edit.edit
+	edit.edit = func(doc *yamlop.Document) (changes []string, err error) {
+		changes, err = setTag(doc)
+		if err != nil {
+			return nil, err
+		}
+		return append(changes, fmt.Sprintf("Promote %s from %s(%s), replacing %s", sourceTag, from, sourcePath, deployedTag)), nil
+	}
+	return []fileEdit{edit}, nil
+}
+
+// PlanPromote tells what the promotion would do without reading kubernetes-configs, so a promotion to prod can be held for confirmation. texts is interpreted as Promote does
+func PlanPromote(k8sRepo config.KubernetesConfigsRepo, texts []string) (plan PromotePlan, err error) {
+	args, err := parsePromote(k8sRepo, texts)
+	if err != nil {
+		return plan, err
+	}
+	return PromotePlan{
+		Repo:    args.codebase.Repo,
+		Project: args.project,
+		From:    args.from,
+		To:      args.to,
+		Image:   args.image,
+		DryRun:  args.dryRun,
+	}, nil
+}
+
+func parsePromote(k8sRepo config.KubernetesConfigsRepo, texts []string) (args promoteArgs, err error) {
+	if len(texts) < 1 {
+		return args, errors.New("call help")
+	}
+
+	repoNameInCMD, texts := pop(texts, 0)
+	args.codebase, err = findCodebase(k8sRepo.Configs, repoNameInCMD)
+	if err != nil {
+		return args, err
+	}
+
+	texts, args.from, err = popValue(texts, "from", "=")
+	if err != nil {
+		return args, errors.Wrap(err, "getting from for promotion encountered an error")
+	}
+	texts, args.to, err = popValue(texts, "to", "=")
+	if err != nil {
+		return args, errors.Wrap(err, "getting to for promotion encountered an error")
+	}
+	if args.from == args.to {
+		return args, errors.Errorf("can't promote from %s to itself", args.from)
+	}
+
+	// project is only required by prod of type 2 codebases, and the path resolution will tell
+	texts, args.project, _ = popValue(texts, "project", "=")
+	texts, args.image = popImage(texts)
+
+	texts, args.dryRun, err = popDryRun(texts)
+	if err != nil {
+		return args, err
+	}
+	texts, args.override, err = popOverride(texts)
+	if err != nil {
+		return args, err
+	}
+	texts, args.force, err = popBool(texts, "force")
+	if err != nil {
+		return args, err
+	}
+
+	if len(texts) != 0 {
+		return args, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+	return args, nil
+}
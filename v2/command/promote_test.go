@@ -0,0 +1,135 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+)
+
+// newTestRepository creates kubernetes-configs in memory, which has the files committed one commit after another
+func newTestRepository(t *testing.T, commits []map[string]string) *gitop.Repository {
+	t.Helper()
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, files := range commits {
+		for path, content := range files {
+			if err := util.WriteFile(worktree.Filesystem, path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := worktree.Add(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := worktree.Commit("test", &git.CommitOptions{
+			Author: &object.Signature{Name: "tester", When: time.Unix(int64(i), 0)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gitop.NewRepository("test repo", r)
+}
+
+// testKustomization is a kustomization with the only image at tag
+func testKustomization(tag string) string {
+	return "images:\n- name: gcr.io/mirrormedia-1470651750304/mirror-tv-nuxt\n  newTag: " + tag + "\n"
+}
+
+func Test_planPromotion(t *testing.T) {
+	codebase := config.Codebase{Type: 1, Repo: "mirror-tv-nuxt", Stages: []string{"dev", "staging", "prod"}}
+	path := func(stage string) string {
+		p, err := codebase.GetImageKustomizationPath(stage, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	k8sRepo := config.KubernetesConfigsRepo{
+		Configs: []config.Codebase{codebase},
+		TagPolicies: map[string]config.TagPolicy{
+			"prod": {Pattern: "^prod_[0-9a-f]{7}$"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		devTag   string
+		from     string
+		to       string
+		force    bool
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name:     "tag allowed in prod",
+			devTag:   "dev_1bac23f",
+			from:     "staging",
+			to:       "prod",
+			wantPath: path("prod"),
+		},
+		{
+			name:    "dev tag refused by the tag policy of prod",
+			devTag:  "dev_1bac23f",
+			from:    "dev",
+			to:      "prod",
+			wantErr: true,
+		},
+		{
+			name:     "stage without tag policy",
+			devTag:   "dev_1bac23f",
+			from:     "dev",
+			to:       "staging",
+			wantPath: path("staging"),
+		},
+		{
+			name:    "tag never on staging",
+			devTag:  "prod_0abc12d",
+			from:    "dev",
+			to:      "prod",
+			wantErr: true,
+		},
+		{
+			name:     "tag never on staging but forced",
+			devTag:   "prod_0abc12d",
+			from:     "dev",
+			to:       "prod",
+			force:    true,
+			wantPath: path("prod"),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepository(t, []map[string]string{
+				{
+					path("dev"):     testKustomization(tt.devTag),
+					path("staging"): testKustomization("prod_399440e"),
+					path("prod"):    testKustomization("prod_81ab7ac"),
+				},
+			})
+			edits, err := planPromotion(repo, k8sRepo, promoteArgs{codebase: &codebase, from: tt.from, to: tt.to, force: tt.force})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planPromotion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(edits) != 1 || edits[0].path != tt.wantPath {
+				t.Errorf("planPromotion() = %+v, want an edit of %s", edits, tt.wantPath)
+			}
+		})
+	}
+}
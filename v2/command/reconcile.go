@@ -0,0 +1,189 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"github.com/pkg/errors"
+)
+
+// reconcileTimeout is how long the GitOps engine has to handle a reconcile request
+const reconcileTimeout = 5 * time.Minute
+
+// reconcileTarget is a path of kubernetes-configs applied to a cluster by its GitOps engine
+type reconcileTarget struct {
+	// label tells the target in the messages, like openwarehouse(tv/dev)
+	label   string
+	path    string
+	cluster *k8sop.Cluster
+}
+
+// appendReconcileTarget appends the target unless the cluster already has its path, as a GitOps resource is reconciled once for all its services
+func appendReconcileTarget(targets []reconcileTarget, target reconcileTarget) []reconcileTarget {
+	for _, t := range targets {
+		if t.cluster == target.cluster && t.path == target.path {
+			return targets
+		}
+	}
+	return append(targets, target)
+}
+
+// Reconcile asks the GitOps engine to fetch kubernetes-configs and apply the kustomization of a service, or all services of a repo, right away. texts is interpreted as [service|repo, env=value, project=value]
+func Reconcile(ctx context.Context, clusters *k8sop.ClusterResolver, k8sRepo config.KubernetesConfigsRepo, texts []string, message string) (messages []string, err error) {
+	if len(texts) < 1 {
+		return nil, errors.New("call help")
+	}
+
+	name, texts := pop(texts, 0)
+	codebase, services, err := resolveServices(k8sRepo.Configs, name)
+	if err != nil {
+		return nil, err
+	}
+
+	texts, stage, err := popValue(texts, "env", "=")
+	if err != nil {
+		return nil, errors.Wrap(err, "getting env for reconcile encountered an error")
+	}
+
+	// project is optional because it can be implied by the service in most cases
+	texts, project, _ := popValue(texts, "project", "=")
+
+	if len(texts) != 0 {
+		return nil, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+
+	var targets []reconcileTarget
+	for _, service := range services {
+		if project != "" && service.Project != "" && service.Project != project {
+			continue
+		}
+		serviceProject, err := resolveProject(*codebase, service, project)
+		if err != nil {
+			return nil, err
+		}
+		path, err := codebase.GetImageKustomizationPath(stage, serviceProject)
+		if err != nil {
+			return nil, err
+		}
+		cluster, err := clusters.Resolve(codebase.Repo, serviceProject, stage)
+		if err != nil {
+			return nil, err
+		}
+		targets = appendReconcileTarget(targets, reconcileTarget{
+			label:   fmt.Sprintf("%s(%s/%s)", name, serviceProject, stage),
+			path:    path,
+			cluster: cluster,
+		})
+	}
+	if len(targets) == 0 {
+		return nil, errors.Errorf("%s has no service for project(%s)", name, project)
+	}
+
+	requestedAt := time.Now().Format(time.RFC3339Nano)
+	messages, err = requestReconcile(ctx, targets, requestedAt)
+	if err != nil {
+		return messages, err
+	}
+
+	// the engine takes a while to fetch and apply kubernetes-configs, so the result follows up if it can
+	waiting := fmt.Sprintf("waiting up to %s for the reconcile", reconcileTimeout)
+	followUp := followUpFrom(ctx)
+	if followUp == nil {
+		reconciled, err := waitForReconcile(targets, requestedAt)
+		return append(append(messages, waiting), reconciled...), err
+	}
+	followUp.Expect()
+	go func() {
+		followUp.Post(waitForReconcile(targets, requestedAt))
+	}()
+	return append(messages, waiting), nil
+}
+
+// requestReconcile requests the GitOps engines to sync the targets, which is handled as requestedAt
+func requestReconcile(ctx context.Context, targets []reconcileTarget, requestedAt string) (messages []string, err error) {
+	for _, t := range targets {
+		resource, err := t.cluster.RequestSync(ctx, t.path, requestedAt)
+		if err != nil {
+			return messages, errors.Wrap(err, fmt.Sprintf("requesting %s to reconcile %s has error", t.cluster.GitOpsName(), t.label))
+		}
+		messages = append(messages, fmt.Sprintf("requested %s %s to reconcile %s", t.cluster.GitOpsName(), resource, t.label))
+	}
+	return messages, nil
+}
+
+// waitForReconcile waits for the GitOps engines of the targets to handle the request at requestedAt. A handled request still fails if the resource isn't ready afterwards
+func waitForReconcile(targets []reconcileTarget, requestedAt string) (messages []string, err error) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancelFn()
+
+	start := time.Now()
+	var failures []string
+	for _, t := range targets {
+		sync, err := t.cluster.WaitForSyncRequest(ctx, t.path, requestedAt, rolloutInterval)
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			failures = append(failures, fmt.Sprintf("%s didn't reconcile %s in %s: %s", t.cluster.GitOpsName(), t.label, reconcileTimeout, sync))
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("checking %s reconciling %s has error: %s", t.cluster.GitOpsName(), t.label, err))
+		case sync.Ready.Status == "False":
+			failures = append(failures, fmt.Sprintf("%s reconciled %s but it's not ready: %s", t.cluster.GitOpsName(), t.label, sync))
+		default:
+			messages = append(messages, fmt.Sprintf("%s reconciled %s in %s: %s", t.cluster.GitOpsName(), t.label, time.Since(start).Round(time.Second), sync))
+		}
+	}
+	if len(failures) != 0 {
+		return messages, errors.New(strings.Join(failures, "\n"))
+	}
+	return messages, nil
+}
+
+// reconcileTargets resolves the kustomizations changed by a deployment of the codebase to the projects in the stage, and the clusters of the services applying them
+func reconcileTargets(clusters *k8sop.ClusterResolver, codebase config.Codebase, stage string, projects []string) ([]reconcileTarget, error) {
+	services, err := codebase.GetServices()
+	if err != nil {
+		return nil, err
+	}
+	var targets []reconcileTarget
+	for _, service := range services {
+		for _, project := range serviceProjects(service, projects) {
+			label := fmt.Sprintf("%s(%s/%s)", codebase.Repo, project, stage)
+			if project == "" {
+				label = fmt.Sprintf("%s(%s)", codebase.Repo, stage)
+			}
+			path, err := codebase.GetImageKustomizationPath(stage, project)
+			if err != nil {
+				return targets, errors.Wrap(err, fmt.Sprintf("auto-reconcile of %s has error", label))
+			}
+			cluster, err := clusters.Resolve(codebase.Repo, project, stage)
+			if err != nil {
+				return targets, errors.Wrap(err, fmt.Sprintf("auto-reconcile of %s has error", label))
+			}
+			targets = appendReconcileTarget(targets, reconcileTarget{
+				label:   label,
+				path:    path,
+				cluster: cluster,
+			})
+		}
+	}
+	return targets, nil
+}
+
+// autoReconcile requests the GitOps engines to reconcile the kustomizations changed by a deployment of the codebase to the projects if it has autoReconcile. The deployment is pushed already, so the errors are only reported
+func autoReconcile(ctx context.Context, clusters *k8sop.ClusterResolver, codebase config.Codebase, stage string, projects []string) (messages []string) {
+	if !codebase.AutoReconcile {
+		return nil
+	}
+	targets, err := reconcileTargets(clusters, codebase, stage, projects)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	messages, err = requestReconcile(ctx, targets, time.Now().Format(time.RFC3339Nano))
+	if err != nil {
+		messages = append(messages, fmt.Sprintf("auto-reconcile has error: %s", err))
+	}
+	return messages
+}
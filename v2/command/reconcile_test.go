@@ -0,0 +1,175 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func Test_reconcile(t *testing.T) {
+	requestedAt := "2026-10-18T08:00:00Z"
+	newCluster := func(lastHandledReconcileAt, ready string) *k8sop.Cluster {
+		kustomization := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": k8sop.KustomizationGVR.GroupVersion().String(),
+			"kind":       "Kustomization",
+			"metadata":   map[string]interface{}{"name": "openwarehouse-dev", "namespace": "flux-system"},
+			"spec":       map[string]interface{}{"path": "./openwarehouse/overlays/dev"},
+			"status": map[string]interface{}{
+				"lastAppliedRevision":    "main@sha1:1bac23",
+				"lastHandledReconcileAt": lastHandledReconcileAt,
+				"conditions":             []interface{}{map[string]interface{}{"type": "Ready", "status": ready}},
+			},
+		}}
+		return &k8sop.Cluster{
+			GitOps: k8sop.Flux{
+				Client: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+					k8sop.KustomizationGVR: "KustomizationList",
+				}, kustomization),
+				Namespace: "flux-system",
+			},
+		}
+	}
+	path := "openwarehouse/overlays/dev/kustomization.yaml"
+	tests := []struct {
+		name    string
+		cluster *k8sop.Cluster
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "reconciled",
+			cluster: newCluster(requestedAt, "True"),
+			want:    "Flux reconciled openwarehouse(tv/dev) in 0s: Kustomization flux-system/openwarehouse-dev applied main@sha1:1bac23, Ready True",
+		},
+		{
+			name:    "reconciled but not ready",
+			cluster: newCluster(requestedAt, "False"),
+			wantErr: "Flux reconciled openwarehouse(tv/dev) but it's not ready: ",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			targets := appendReconcileTarget(nil, reconcileTarget{label: "openwarehouse(tv/dev)", path: path, cluster: tt.cluster})
+			// the same kustomization is requested once
+			targets = appendReconcileTarget(targets, reconcileTarget{label: "openwarehouse-tv-cms(tv/dev)", path: path, cluster: tt.cluster})
+			messages, err := requestReconcile(context.Background(), targets, requestedAt)
+			if err != nil || len(messages) != 1 || messages[0] != "requested Flux Kustomization flux-system/openwarehouse-dev to reconcile openwarehouse(tv/dev)" {
+				t.Fatalf("requestReconcile() = %v, %v", messages, err)
+			}
+
+			messages, err = waitForReconcile(targets, requestedAt)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("waitForReconcile() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(messages) != 1 || messages[0] != tt.want {
+				t.Errorf("waitForReconcile() = %v, %v, want %v", messages, err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reconcileTargets(t *testing.T) {
+	kubeConfig := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(kubeConfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://tv.example.com
+  name: tv
+contexts:
+- context:
+    cluster: tv
+    user: major-tom
+  name: tv
+current-context: tv
+users:
+- name: major-tom
+  user:
+    token: token
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters, err := k8sop.NewClusterResolver(config.Clusters{
+		{Repo: "mirror-tv-nuxt", Stage: "dev", KubeConfigPath: kubeConfig},
+		{Project: "tv", Stage: "dev", KubeConfigPath: kubeConfig},
+		{Project: "tv", Stage: "prod", KubeConfigPath: kubeConfig},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	openwarehouse := config.Codebase{Type: 2, Repo: "openwarehouse", Stages: []string{"dev", "prod"}, Projects: []string{"tv"}, Services: []string{"cms", "gql-external"}}
+	tests := []struct {
+		name      string
+		codebase  config.Codebase
+		stage     string
+		projects  []string
+		want      []string
+		wantPaths []string
+		wantErr   bool
+	}{
+		{
+			name:      "type 1 codebase without projects",
+			codebase:  config.Codebase{Type: 1, Repo: "mirror-tv-nuxt", Stages: []string{"dev", "prod"}},
+			stage:     "dev",
+			want:      []string{"mirror-tv-nuxt(dev)"},
+			wantPaths: []string{"mirror-tv-nuxt/overlays/dev/kustomization.yaml"},
+		},
+		{
+			name:      "type 2 services share the kustomization of the stage",
+			codebase:  openwarehouse,
+			stage:     "dev",
+			projects:  []string{"tv"},
+			want:      []string{"openwarehouse(tv/dev)"},
+			wantPaths: []string{"openwarehouse/overlays/dev/base/kustomization.yaml"},
+		},
+		{
+			name:      "type 2 project in prod",
+			codebase:  openwarehouse,
+			stage:     "prod",
+			projects:  []string{"tv"},
+			want:      []string{"openwarehouse(tv/prod)"},
+			wantPaths: []string{"openwarehouse/overlays/prod/overlays/tv/base/kustomization.yaml"},
+		},
+		{
+			name:     "type 1 codebase without a cluster",
+			codebase: config.Codebase{Type: 1, Repo: "mirror-tv-nuxt", Stages: []string{"dev", "prod"}},
+			stage:    "prod",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := reconcileTargets(clusters, tt.codebase, tt.stage, tt.projects)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got, gotPaths []string
+			for _, target := range targets {
+				got = append(got, target.label)
+				gotPaths = append(gotPaths, target.path)
+				if target.cluster == nil {
+					t.Errorf("reconcileTargets() resolved no cluster for %s", target.label)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(gotPaths, tt.wantPaths) {
+				t.Errorf("reconcileTargets() = %v at %v, want %v at %v", got, gotPaths, tt.want, tt.wantPaths)
+			}
+		})
+	}
+}
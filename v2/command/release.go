@@ -2,74 +2,362 @@ package command
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/mirror-media/major-tom-go/v2/config"
-	mjcontext "github.com/mirror-media/major-tom-go/v2/internal/context"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
 	"github.com/pkg/errors"
 )
 
-// Release a new image tag to a repo in a project. texts is interpreted as [project=value, imag-tag=value]
-func Release(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []string, message, caller string) (messages []string, err error) {
+// ReleasePlan describes the image tags a release is going to replace in prod
+type ReleasePlan struct {
+	Repo     string
+	Projects []string
+	Tags     []TagChange
+	DryRun   bool
+}
+
+// TagChange is the tag of an image in a project to be replaced. Image is empty for the image owned by the repo
+type TagChange struct {
+	Project     string
+	Image       string
+	DeployedTag string
+	ImageTag    string
+}
+
+type releaseArgs struct {
+	codebase  *config.Codebase
+	projects  []string
+	imageTags []imageTag
+	dryRun    bool
+	override  bool
+	// force releases the tags which were never on staging
+	force bool
+	// wait waits for the rollout and posts it as a follow-up
+	wait bool
+}
+
+// Release new image tags to a repo in projects in one commit. texts is interpreted as [project=value|all, imag-tag=value, image=name] or [projects=value,value, image-tag.name=value, ...]
+func Release(ctx context.Context, clusters *k8sop.ClusterResolver, k8sRepo config.KubernetesConfigsRepo, texts []string, message, caller string) (messages []string, err error) {
 	if !DeployWorker.isRunning {
 		return nil, errors.New("deploy worker is not running")
 	}
 
-	if len(texts) < 1 {
-		return nil, errors.New("call help")
+	args, err := parseRelease(k8sRepo, texts)
+	if err != nil {
+		return nil, err
 	}
+	codebase := args.codebase
 
-	// Compare and retrieve the repo before we engage the deployment, so we can pass the repo to deploy worker for clearer intention
-	codebases := k8sRepo.Configs
-	repoNameInCMD, texts := pop(texts, 0)
-	var codebase *config.Codebase
-	for _, c := range codebases {
-		if repoNameInCMD == c.Repo {
-			codebase = &c
-			break
+	// the rollout is watched if it's waited for or rolled back on failure, but a dry run has nothing to watch
+	isAutoRollback := codebase.AutoRollback.Enabled && !args.dryRun
+	isWatched := isAutoRollback || (args.wait && !args.dryRun)
+	followUp := followUpFrom(ctx)
+	var targets []rolloutTarget
+	timeout := DeployWorker.getRolloutTimeout()
+	if isWatched {
+		if args.wait && followUp == nil {
+			return nil, errors.New("wait=true is not supported here")
+		}
+		targets, err = rolloutTargets(clusters, *codebase, "prod", args.projects)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if isAutoRollback {
+		timeout, err = codebase.AutoRollback.GetTimeout(timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var edits []fileEdit
+	var paths []string
+	for _, project := range args.projects {
+		projectEdits, err := imageTagEdits(*codebase, "prod", project, args.imageTags)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, projectEdits[0].path)
+		if len(args.projects) > 1 {
+			projectEdits = withProject(projectEdits, project)
+		}
+		edits = append(edits, projectEdits...)
+	}
+	err = verifyImageTags(ctx, *codebase, paths, args.imageTags, message)
+	if err != nil {
+		return nil, err
+	}
+	var refs []imageTag
+	if isWatched {
+		refs, err = resolveImageTags(ctx, *codebase, paths, args.imageTags, message)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// previous are the image tags of the projects replaced by the release, which are read by the deploy worker right before the change
+	previous := make([][]imageTag, len(args.projects))
+	deployment := Deployment{
+		// the history of staging is checked against the up-to-date kubernetes-configs
+		plan: func(repo *gitop.Repository) ([]fileEdit, error) {
+			err := checkStaged(repo, args)
+			if err != nil || !isAutoRollback {
+				return nil, err
+			}
+			for i := range args.projects {
+				previous[i] = readPreviousTags(repo, paths[i], *codebase, args.imageTags)
+			}
+			return nil, nil
+		},
+		codebase:  codebase,
+		stage:     "prod",
+		imageTags: args.imageTags,
+		caller:    caller,
+		message:   message,
+		title:     deployTitle(codebase.Repo, "prod", strings.Join(args.projects, ","), caller),
+		edits:     edits,
+		dryRun:    args.dryRun,
+		override:  args.override,
+	}
+	if len(args.projects) == 1 {
+		deployment.project = args.projects[0]
+	} else {
+		deployment.batchProjects = args.projects
+	}
+	pushed := queueDeployment(ctx, deployment)
+	if pushed.Error != nil || pushed.Commit == "" {
+		return pushed.Messages, pushed.Error
+	}
+	messages = append(pushed.Messages, autoReconcile(ctx, clusters, *codebase, "prod", args.projects)...)
+	if !isWatched {
+		return messages, nil
+	}
+
+	watches := releaseWatches(codebase, args, targets, paths, pushed.Commit, refs, previous, timeout, message)
+	waiting := fmt.Sprintf("waiting up to %s for the rollout", timeout)
+	if isAutoRollback {
+		waiting += ", which is rolled back if it fails"
+	}
+	watchRollouts(followUp, message, watches)
+	return append(messages, waiting), nil
+}
+
+// releaseWatches watches the rollout of each project at its path. With auto-rollback, a project failing to roll out is rolled back to its previous image tags on its own
+func releaseWatches(codebase *config.Codebase, args releaseArgs, targets []rolloutTarget, paths []string, commit string, refs []imageTag, previous [][]imageTag, timeout time.Duration, message string) []rolloutWatch {
+	watches := make([]rolloutWatch, len(args.projects))
+	for i, project := range args.projects {
+		i, project := i, project
+		watches[i] = rolloutWatch{
+			targets: projectTargets(targets, project),
+			path:    paths[i],
+			commit:  commit,
+			tags:    refs,
+			timeout: timeout,
+		}
+		if codebase.AutoRollback.Enabled {
+			watches[i].rollback = func() ([]string, error) {
+				return autoRollback(codebase, "prod", project, paths[i], args.imageTags, previous[i], fmt.Sprintf("auto-rollback of \"%s\"", message))
+			}
+		}
+	}
+	return watches
+}
+
+// withProject prefixes the changes of the edits with the project, so the changes of a batch can be told apart
+func withProject(edits []fileEdit, project string) []fileEdit {
+	for i := range edits {
+		edit := edits[i].edit
+		edits[i].edit = func(doc *yamlop.Document) (changes []string, err error) {
+			changes, err = edit(doc)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("project(%s)", project))
+			}
+			for j := range changes {
+				changes[j] = fmt.Sprintf("project(%s): %s", project, changes[j])
+			}
+			return changes, nil
+		}
+	}
+	return edits
+}
+
+// PlanRelease reads the image tags in prod which the release would replace. texts is interpreted as Release does
+func PlanRelease(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []string, message string) (plan ReleasePlan, err error) {
+	if !DeployWorker.isRunning {
+		return plan, errors.New("deploy worker is not running")
+	}
+
+	args, err := parseRelease(k8sRepo, texts)
+	if err != nil {
+		return plan, err
+	}
+	paths := make([]string, len(args.projects))
+	for i, project := range args.projects {
+		paths[i], err = args.codebase.GetImageKustomizationPath("prod", project)
+		if err != nil {
+			return plan, err
 		}
 	}
 
-	if codebase == nil {
-		return nil, errors.New("invalid repo name")
+	// a release waiting for the confirmation of a tag not found would be refused anyway
+	err = verifyImageTags(ctx, *args.codebase, paths, args.imageTags, message)
+	if err != nil {
+		return plan, err
+	}
+
+	plan = ReleasePlan{
+		Repo:     args.codebase.Repo,
+		Projects: args.projects,
+		DryRun:   args.dryRun,
+	}
+	_, err = ask(ctx, message, func(repo *gitop.Repository) (messages []string, err error) {
+		err = checkStaged(repo, args)
+		if err != nil {
+			return nil, err
+		}
+		for i, project := range args.projects {
+			for _, t := range args.imageTags {
+				deployedTag, err := readImageTag(repo, paths[i], *args.codebase, t.image)
+				if err != nil {
+					return nil, err
+				}
+				plan.Tags = append(plan.Tags, TagChange{Project: project, Image: t.image, DeployedTag: deployedTag, ImageTag: t.tag})
+			}
+		}
+		return nil, nil
+	})
+	return plan, err
+}
+
+func parseRelease(k8sRepo config.KubernetesConfigsRepo, texts []string) (args releaseArgs, err error) {
+	if len(texts) < 1 {
+		return args, errors.New("call help")
+	}
+
+	// Compare and retrieve the repo before we engage the deployment, so we can pass the repo to deploy worker for clearer intention
+	repoNameInCMD, texts := pop(texts, 0)
+	args.codebase, err = findCodebase(k8sRepo.Configs, repoNameInCMD)
+	if err != nil {
+		return args, err
 	}
 
 	// deploy requires project only and it retquires image-tag and project
 
-	texts, project, err := popValue(texts, "project", "=")
+	texts, args.projects, err = popProjects(texts, *args.codebase)
 	if err != nil {
-		return nil, errors.Wrap(err, "getting project for deployment encountered an error")
+		return args, errors.Wrap(err, "getting project for deployment encountered an error")
 	}
 
-	texts, image, err := popValue(texts, "image-tag", "=")
+	texts, args.imageTags, err = popImageTags(texts)
+	if err != nil {
+		return args, errors.Wrap(err, "getting image-tag for deployment encountered an error")
+	}
+	err = checkImageTags(k8sRepo, *args.codebase, "prod", args.imageTags)
+	if err != nil {
+		return args, err
+	}
+
+	texts, args.dryRun, err = popDryRun(texts)
+	if err != nil {
+		return args, err
+	}
+	texts, args.override, err = popOverride(texts)
+	if err != nil {
+		return args, err
+	}
+	texts, args.force, err = popBool(texts, "force")
 	if err != nil {
-		return nil, errors.Wrap(err, "getting image-tag for deployment encountered an error")
+		return args, err
+	}
+	texts, args.wait, err = popBool(texts, "wait")
+	if err != nil {
+		return args, err
 	}
 
 	if len(texts) != 0 {
-		return nil, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
-	}
-
-	timeout := 5 * time.Minute
-	ch := make(chan response)
-	newCtx := context.WithValue(ctx, mjcontext.ResponseChannel, ch)
-	newCtx, cancelFn := context.WithTimeout(newCtx, timeout)
-	defer cancelFn()
-	deployChannel <- Deployment{
-		ctx:      newCtx,
-		codebase: codebase,
-		stage:    "prod",
-		project:  project,
-		imageTag: image,
-		caller:   caller,
-		message:  message,
-	}
-
-	select {
-	case commandResponse := <-ch:
-		return commandResponse.Messages, commandResponse.Error
-	case <-newCtx.Done():
-		return nil, errors.Errorf("\"%s\" command has timeouted(%f)", strings.Join(texts, " "), timeout.Minutes())
+		return args, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+	return args, nil
+}
+
+// checkStaged returns an error for the tags which were never on staging for the projects, unless the release is forced. A tag is on staging if it's deployed there now or in the history of the staging kustomization
+func checkStaged(repo *gitop.Repository, args releaseArgs) error {
+	codebase := *args.codebase
+	if args.force || codebase.SkipStagingCheck || !codebase.HasStage("staging") {
+		return nil
+	}
+	checked := make(map[string]bool)
+	for _, project := range args.projects {
+		path, err := codebase.GetImageKustomizationPath("staging", project)
+		if err != nil {
+			return err
+		}
+		// projects of type 2 codebases share the staging kustomization
+		if checked[path] {
+			continue
+		}
+		checked[path] = true
+
+		for _, t := range args.imageTags {
+			history, err := getTagHistory(repo, path, codebase, t.image)
+			if err != nil {
+				return err
+			}
+			if !isStaged(history, t.tag) {
+				return errors.Errorf("tag %s was never on staging in the latest %d changes of %s. Add force=true to release it anyway", t.tag, len(history), path)
+			}
+		}
+	}
+	return nil
+}
+
+// isStaged reports whether the tag is in the history of the staging kustomization, which starts with the tag deployed now
+func isStaged(history []tagChange, tag string) bool {
+	for _, c := range history {
+		if c.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// popProjects pops project=value, project=all for all projects of the codebase, or projects=value,value. Only type 2 codebases have a prod kustomization for each project to release more than one project
+func popProjects(texts []string, codebase config.Codebase) (newTexts []string, projects []string, err error) {
+	newTexts, list, errList := popValue(texts, "projects", "=")
+	newTexts, project, errProject := popValue(newTexts, "project", "=")
+	switch {
+	case errList == nil && errProject == nil:
+		return newTexts, nil, errors.New("project and projects can't be used together")
+	case errList == nil:
+		projects = strings.Split(list, ",")
+	case errProject != nil:
+		return newTexts, nil, errProject
+	case project == "all":
+		projects = codebase.Projects
+	default:
+		return newTexts, []string{project}, nil
+	}
+
+	if codebase.Type != 2 {
+		return newTexts, nil, errors.Errorf("%s has one prod kustomization for all projects, so release it with project=value", codebase.Repo)
+	}
+	if len(projects) == 0 {
+		return newTexts, nil, errors.Errorf("%s has no projects", codebase.Repo)
+	}
+	seen := make(map[string]bool)
+	for _, p := range projects {
+		if !codebase.HasProject(p) {
+			return newTexts, nil, errors.Errorf("project(%s) is not supported for %s", p, codebase.Repo)
+		}
+		if seen[p] {
+			return newTexts, nil, errors.Errorf("project(%s) is given more than once", p)
+		}
+		seen[p] = true
 	}
+	return newTexts, projects, nil
 }
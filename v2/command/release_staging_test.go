@@ -0,0 +1,134 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/mirror-media/major-tom-go/v2/config"
+)
+
+func Test_isStaged(t *testing.T) {
+	// latest first, as the history of the staging kustomization
+	history := []tagChange{
+		{hash: plumbing.NewHash("3333333333333333333333333333333333333333"), tag: "staging_c"},
+		{hash: plumbing.NewHash("2222222222222222222222222222222222222222"), tag: "prod_399440e"},
+		{hash: plumbing.NewHash("1111111111111111111111111111111111111111"), tag: "staging_a"},
+	}
+	tests := []struct {
+		name string
+		tag  string
+		want bool
+	}{
+		{
+			name: "deployed on staging now",
+			tag:  "staging_c",
+			want: true,
+		},
+		{
+			name: "deployed on staging before",
+			tag:  "prod_399440e",
+			want: true,
+		},
+		{
+			name: "never on staging",
+			tag:  "prod_81ab7ac",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStaged(history, tt.tag); got != tt.want {
+				t.Errorf("isStaged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkStaged(t *testing.T) {
+	openwarehouse := config.Codebase{Type: 2, Repo: "openwarehouse", Stages: []string{"dev", "staging", "prod"}, Projects: []string{"tv", "readr"}}
+	nuxt := config.Codebase{Type: 1, Repo: "mirror-tv-nuxt", Stages: []string{"dev", "staging", "prod"}}
+	stagingPath := func(codebase config.Codebase) string {
+		p, err := codebase.GetImageKustomizationPath("staging", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	// openwarehouse had prod_399440e on staging before prod_1bac23f
+	repo := newTestRepository(t, []map[string]string{
+		{stagingPath(openwarehouse): testKustomization("prod_399440e"), stagingPath(nuxt): testKustomization("prod_0abc12d")},
+		{stagingPath(openwarehouse): testKustomization("prod_1bac23f")},
+	})
+	skipped := nuxt
+	skipped.SkipStagingCheck = true
+	withoutStaging := nuxt
+	withoutStaging.Stages = []string{"dev", "prod"}
+
+	tests := []struct {
+		name     string
+		codebase config.Codebase
+		projects []string
+		tag      string
+		force    bool
+		wantErr  bool
+	}{
+		{
+			name:     "on staging now",
+			codebase: openwarehouse,
+			projects: []string{"tv"},
+			tag:      "prod_1bac23f",
+		},
+		{
+			name:     "on staging before",
+			codebase: openwarehouse,
+			projects: []string{"tv", "readr"},
+			tag:      "prod_399440e",
+		},
+		{
+			name:     "never on staging",
+			codebase: openwarehouse,
+			projects: []string{"tv"},
+			tag:      "prod_81ab7ac",
+			wantErr:  true,
+		},
+		{
+			name:     "staging of another codebase",
+			codebase: nuxt,
+			projects: []string{""},
+			tag:      "prod_399440e",
+			wantErr:  true,
+		},
+		{
+			name:     "forced",
+			codebase: openwarehouse,
+			projects: []string{"tv"},
+			tag:      "prod_81ab7ac",
+			force:    true,
+		},
+		{
+			name:     "skipStagingCheck",
+			codebase: skipped,
+			projects: []string{""},
+			tag:      "prod_81ab7ac",
+		},
+		{
+			name:     "codebase without staging",
+			codebase: withoutStaging,
+			projects: []string{""},
+			tag:      "prod_81ab7ac",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStaged(repo, releaseArgs{
+				codebase:  &tt.codebase,
+				projects:  tt.projects,
+				imageTags: []imageTag{{tag: tt.tag}},
+				force:     tt.force,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkStaged() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
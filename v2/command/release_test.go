@@ -80,15 +80,16 @@ func TestRelease(t *testing.T) {
 			wantErr: true,
 		},
 		{
+			// the random tag was never on staging
 			name: "release openwarehouse tv image-tag",
 			args: args{
 				caller:  "+tester",
 				k8sRepo: test.K8sRepo,
 				ctx:     context.TODO(),
-				texts:   []string{"openwarehouse", "project=tv", "image-tag=" + newTag},
-				message: "release openwarehouse project=tv image-tag=" + newTag,
+				texts:   []string{"openwarehouse", "project=tv", "image-tag=" + newTag, "force=true"},
+				message: "release openwarehouse project=tv image-tag=" + newTag + " force=true",
 			},
-			wantMessages: []string{"deploy(openwarehouse/prod/tv): deployed by +tester", "", "Set image-tag(images.0.newTag) to " + newTag, "by \"release openwarehouse project=tv image-tag=" + newTag + "\""},
+			wantMessages: []string{"deploy(openwarehouse/prod/tv): deployed by +tester", "", "Set image-tag(images.0.newTag) of gcr.io/mirrormedia-1470651750304/openwarehouse from " + deployedTag + " to " + newTag, "by \"release openwarehouse project=tv image-tag=" + newTag + " force=true\""},
 		},
 		{
 			name: "release mirror-tv-nuxt tv image-tag",
@@ -96,10 +97,10 @@ func TestRelease(t *testing.T) {
 				caller:  "+tester",
 				k8sRepo: test.K8sRepo,
 				ctx:     context.TODO(),
-				texts:   []string{"mirror-tv-nuxt", "project=tv", "image-tag=" + newTag},
-				message: "release mirror-tv-nuxt project=tv image-tag=" + newTag,
+				texts:   []string{"mirror-tv-nuxt", "project=tv", "image-tag=" + newTag, "force=true"},
+				message: "release mirror-tv-nuxt project=tv image-tag=" + newTag + " force=true",
 			},
-			wantMessages: []string{"deploy(mirror-tv-nuxt/prod/tv): deployed by +tester", "", "Set image-tag(images.0.newTag) to " + newTag, "by \"release mirror-tv-nuxt project=tv image-tag=" + newTag + "\""},
+			wantMessages: []string{"deploy(mirror-tv-nuxt/prod/tv): deployed by +tester", "", "Set image-tag(images.0.newTag) of gcr.io/mirrormedia-1470651750304/mirror-tv-nuxt from " + deployedTag + " to " + newTag, "by \"release mirror-tv-nuxt project=tv image-tag=" + newTag + " force=true\""},
 		},
 		{
 			name: "can't release without project",
@@ -127,12 +128,12 @@ func TestRelease(t *testing.T) {
 	DeployWorker.Set(test.K8sRepo.Git)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotMessages, err := Release(tt.args.ctx, tt.args.k8sRepo, tt.args.texts, tt.args.message, tt.args.caller)
+			gotMessages, err := Release(tt.args.ctx, nil, tt.args.k8sRepo, tt.args.texts, tt.args.message, tt.args.caller)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Release() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(gotMessages, tt.wantMessages) {
+			if !reflect.DeepEqual(maskDeployedTag(gotMessages), tt.wantMessages) {
 				t.Errorf("Release() = %v, want %v", gotMessages, tt.wantMessages)
 			}
 		})
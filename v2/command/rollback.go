@@ -0,0 +1,245 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
+	"github.com/pkg/errors"
+)
+
+// rollbackHistorySize is the number of changes of a kustomization searched for a rollback
+const rollbackHistorySize = 100
+
+// tagChange is the image tag in a kustomization after a commit
+type tagChange struct {
+	hash plumbing.Hash
+	tag  string
+}
+
+// RollbackPlan describes the rollback, which is confirmed before it changes prod
+type RollbackPlan struct {
+	Repo    string
+	Project string
+	Stage   string
+	// To is the commit to roll back to, or the number of changes to roll back. It's empty for one change
+	To string
+	// Image is empty for the image owned by the repo
+	Image  string
+	DryRun bool
+}
+
+type rollbackArgs struct {
+	codebase *config.Codebase
+	stage    string
+	project  string
+	to       string
+	image    string
+	dryRun   bool
+	override bool
+}
+
+// Rollback restores the previous image tag of a repo from the git history. texts is interpreted as [repo, env=value, project=value, to=commit|n, image=name]
+func Rollback(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []string, message, caller string) (messages []string, err error) {
+	if !DeployWorker.isRunning {
+		return nil, errors.New("deploy worker is not running")
+	}
+
+	args, err := parseRollback(k8sRepo, texts)
+	if err != nil {
+		return nil, err
+	}
+	codebase, stage, project, to, image := args.codebase, args.stage, args.project, args.to, args.image
+
+	path, err := codebase.GetImageKustomizationPath(stage, project)
+	if err != nil {
+		return nil, err
+	}
+
+	var pendingProject string
+	if project != "" {
+		pendingProject = "/" + project
+	}
+
+	return queue(ctx, Deployment{
+		codebase: codebase,
+		project:  project,
+		stage:    stage,
+		caller:   caller,
+		message:  message,
+		dryRun:   args.dryRun,
+		override: args.override,
+		title:    fmt.Sprintf("rollback(%s/%s%s): rolled back by %s", codebase.Repo, stage, pendingProject, caller),
+		plan: func(repo *gitop.Repository) ([]fileEdit, error) {
+			history, err := getTagHistory(repo, path, *codebase, image)
+			if err != nil {
+				return nil, err
+			}
+			reverted, target, err := selectRollback(history, to)
+			if err != nil {
+				return nil, err
+			}
+			edit, err := imageTagEdit(*codebase, stage, project, image, target.tag)
+			if err != nil {
+				return nil, err
+			}
+			setTag := edit.edit
+			edit.edit = func(doc *yamlop.Document) (changes []string, err error) {
+				changes, err = setTag(doc)
+				if err != nil {
+					return nil, err
+				}
+				return append(changes,
+					fmt.Sprintf("Roll back from %s to %s of commit %s", reverted.tag, target.tag, target.hash),
+					"",
+					fmt.Sprintf("This reverts commit %s.", reverted.hash),
+				), nil
+			}
+			return []fileEdit{edit}, nil
+		},
+	})
+}
+
+// PlanRollback tells what the rollback would do without reading kubernetes-configs, so a rollback of prod can be held for confirmation. texts is interpreted as Rollback does
+func PlanRollback(k8sRepo config.KubernetesConfigsRepo, texts []string) (plan RollbackPlan, err error) {
+	args, err := parseRollback(k8sRepo, texts)
+	if err != nil {
+		return plan, err
+	}
+	return RollbackPlan{
+		Repo:    args.codebase.Repo,
+		Project: args.project,
+		Stage:   args.stage,
+		To:      args.to,
+		Image:   args.image,
+		DryRun:  args.dryRun,
+	}, nil
+}
+
+func parseRollback(k8sRepo config.KubernetesConfigsRepo, texts []string) (args rollbackArgs, err error) {
+	if len(texts) < 1 {
+		return args, errors.New("call help")
+	}
+
+	repoNameInCMD, texts := pop(texts, 0)
+	args.codebase, err = findCodebase(k8sRepo.Configs, repoNameInCMD)
+	if err != nil {
+		return args, err
+	}
+
+	texts, args.stage, err = popValue(texts, "env", "=")
+	if err != nil {
+		return args, errors.Wrap(err, "getting env for rollback encountered an error")
+	}
+
+	// project is only required by prod of type 2 codebases, and the path resolution will tell
+	texts, args.project, _ = popValue(texts, "project", "=")
+	if args.stage != "prod" && args.codebase.Type == 2 {
+		args.project = ""
+	}
+
+	// to is the commit to roll back to, or the number of changes to roll back. It rolls back one change by default
+	texts, args.to, _ = popValue(texts, "to", "=")
+	texts, args.image = popImage(texts)
+
+	texts, args.dryRun, err = popDryRun(texts)
+	if err != nil {
+		return args, err
+	}
+	texts, args.override, err = popOverride(texts)
+	if err != nil {
+		return args, err
+	}
+
+	if len(texts) != 0 {
+		return args, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+	return args, nil
+}
+
+// getTagHistory returns the tags of the image in the kustomization after each commit which changed it, latest first
+func getTagHistory(repo *gitop.Repository, path string, codebase config.Codebase, image string) (history []tagChange, err error) {
+	commits, err := repo.Log(rollbackHistorySize, path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("getting history of %s has error", path))
+	}
+	for _, c := range commits {
+		b, err := repo.ReadFileAtCommit(c.Hash, path)
+		if err != nil {
+			// the file is deleted in this commit, so there's no more history
+			break
+		}
+		kustomization, err := loadYAML(path, b)
+		if err != nil {
+			return nil, err
+		}
+		tag, err := getImageTag(kustomization, codebase, image)
+		if err != nil && len(history) == 0 {
+			return nil, errors.Wrap(err, path)
+		} else if err != nil {
+			// the image is added in this commit, so there's no more history
+			break
+		}
+		history = append(history, tagChange{
+			hash: c.Hash,
+			tag:  tag,
+		})
+	}
+	if len(history) == 0 {
+		return nil, errors.Errorf("%s has no history", path)
+	}
+	return history, nil
+}
+
+// selectRollback finds the change to roll back to by to, which is a commit hash or the number of tag changes to go back, in the history.
+// reverted is the commit introducing the current tag
+func selectRollback(history []tagChange, to string) (reverted, target tagChange, err error) {
+	current := history[0].tag
+	// the commit introducing the current tag is the earliest one having it before the tag changes
+	reverted = history[0]
+	for _, c := range history {
+		if c.tag != current {
+			break
+		}
+		reverted = c
+	}
+
+	if to == "" {
+		to = "1"
+	}
+	// a commit hash is at least 4 characters like git does
+	if n, errAtoi := strconv.Atoi(to); errAtoi == nil && len(to) < 4 {
+		if n < 1 {
+			return reverted, target, errors.Errorf("to(%s) should be a positive number or a commit", to)
+		}
+		previous := current
+		for _, c := range history {
+			if c.tag == previous {
+				continue
+			}
+			previous = c.tag
+			n--
+			if n == 0 {
+				return reverted, c, nil
+			}
+		}
+		return reverted, target, errors.Errorf("there aren't enough changes to roll back in the latest %d commits", len(history))
+	} else if len(to) < 4 {
+		return reverted, target, errors.Errorf("to(%s) should be a positive number or a commit", to)
+	}
+
+	for _, c := range history {
+		if strings.HasPrefix(c.hash.String(), to) {
+			if c.tag == current {
+				return reverted, target, errors.Errorf("image tag is %s in commit %s already", current, c.hash)
+			}
+			return reverted, c, nil
+		}
+	}
+	return reverted, target, errors.Errorf("commit(%s) is not found in the latest %d changes", to, len(history))
+}
@@ -0,0 +1,117 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func Test_selectRollback(t *testing.T) {
+	// latest first. c2 changed something else than the image tag
+	history := []tagChange{
+		{hash: plumbing.NewHash("4444444444444444444444444444444444444444"), tag: "prod_d"},
+		{hash: plumbing.NewHash("3333333333333333333333333333333333333333"), tag: "prod_c"},
+		{hash: plumbing.NewHash("2222222222222222222222222222222222222222"), tag: "prod_b"},
+		{hash: plumbing.NewHash("2bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"), tag: "prod_b"},
+		{hash: plumbing.NewHash("1111111111111111111111111111111111111111"), tag: "prod_a"},
+	}
+	// the current tag is set by 2 commits and the earlier one introduced it
+	historyWithUnchangedTag := append([]tagChange{{hash: plumbing.NewHash("5555555555555555555555555555555555555555"), tag: "prod_d"}}, history...)
+
+	tests := []struct {
+		name         string
+		history      []tagChange
+		to           string
+		wantReverted string
+		wantTarget   string
+		wantErr      bool
+	}{
+		{
+			name:         "previous tag by default",
+			history:      history,
+			wantReverted: "4444444444444444444444444444444444444444",
+			wantTarget:   "3333333333333333333333333333333333333333",
+		},
+		{
+			name:         "commits without tag change are skipped",
+			history:      history,
+			to:           "3",
+			wantReverted: "4444444444444444444444444444444444444444",
+			wantTarget:   "1111111111111111111111111111111111111111",
+		},
+		{
+			name:         "reverted commit is the one introducing the current tag",
+			history:      historyWithUnchangedTag,
+			to:           "1",
+			wantReverted: "4444444444444444444444444444444444444444",
+			wantTarget:   "3333333333333333333333333333333333333333",
+		},
+		{
+			name:         "commit prefix",
+			history:      history,
+			to:           "2bbbbbb",
+			wantReverted: "4444444444444444444444444444444444444444",
+			wantTarget:   "2bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		},
+		{
+			name:         "numeric commit prefix",
+			history:      history,
+			to:           "1111",
+			wantReverted: "4444444444444444444444444444444444444444",
+			wantTarget:   "1111111111111111111111111111111111111111",
+		},
+		{
+			name:    "commit with the current tag",
+			history: historyWithUnchangedTag,
+			to:      "5555",
+			wantErr: true,
+		},
+		{
+			name:    "unknown commit",
+			history: history,
+			to:      "abcdef",
+			wantErr: true,
+		},
+		{
+			name:    "too many changes",
+			history: history,
+			to:      "4",
+			wantErr: true,
+		},
+		{
+			name:    "zero change",
+			history: history,
+			to:      "0",
+			wantErr: true,
+		},
+		{
+			name:    "short commit prefix",
+			history: history,
+			to:      "2bb",
+			wantErr: true,
+		},
+		{
+			name:    "nothing to roll back to",
+			history: history[:1],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotReverted, gotTarget, err := selectRollback(tt.history, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("selectRollback() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if gotReverted.hash.String() != tt.wantReverted {
+				t.Errorf("selectRollback() reverted = %v, want %v", gotReverted.hash, tt.wantReverted)
+			}
+			if gotTarget.hash.String() != tt.wantTarget {
+				t.Errorf("selectRollback() target = %v, want %v", gotTarget.hash, tt.wantTarget)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+	mjcontext "github.com/mirror-media/major-tom-go/v2/internal/context"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// rolloutInterval is how often the deployments are checked while waiting for the rollout
+const rolloutInterval = 5 * time.Second
+
+// FollowUp posts the result of a command after the command has responded, like the rollout of a deployment
+type FollowUp interface {
+	// Expect is called before the command responds if there will be a follow-up, so the response can be its parent
+	Expect()
+	// Notify makes the follow-ups mention the user rather than the user of the command, like the caller of an approved request
+	Notify(userID string)
+	Post(messages []string, err error)
+}
+
+// WithFollowUp returns a context carrying the follow-up of the command
+func WithFollowUp(ctx context.Context, f FollowUp) context.Context {
+	return context.WithValue(ctx, mjcontext.FollowUp, f)
+}
+
+func followUpFrom(ctx context.Context) FollowUp {
+	f, _ := ctx.Value(mjcontext.FollowUp).(FollowUp)
+	return f
+}
+
+// rolloutTarget is a deployment on the cluster rolled out by a deployment in kubernetes-configs
+type rolloutTarget struct {
+	service string
+	project string
+	stage   string
+	cluster *k8sop.Cluster
+}
+
+// serviceProjects returns the projects the service is deployed to among projects. A service without a project is deployed to every project, or to the cluster of its repo if there is no project
+func serviceProjects(service config.Service, projects []string) []string {
+	if service.Project == "" {
+		if len(projects) == 0 {
+			return []string{""}
+		}
+		return projects
+	}
+	for _, project := range projects {
+		if project == service.Project {
+			return []string{project}
+		}
+	}
+	return nil
+}
+
+// rolloutTargets resolves the deployments of the codebase in the stage of the projects and their clusters
+func rolloutTargets(clusters *k8sop.ClusterResolver, codebase config.Codebase, stage string, projects []string) ([]rolloutTarget, error) {
+	services, err := codebase.GetServices()
+	if err != nil {
+		return nil, err
+	}
+	var targets []rolloutTarget
+	for _, service := range services {
+		for _, project := range serviceProjects(service, projects) {
+			cluster, err := clusters.Resolve(codebase.Repo, project, stage)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("waiting for the rollout of %s needs its cluster", service.Name))
+			}
+			targets = append(targets, rolloutTarget{
+				service: service.Name,
+				project: project,
+				stage:   stage,
+				cluster: cluster,
+			})
+		}
+	}
+	return targets, nil
+}
+
+// rolloutWatch waits for the targets to run the image tags, which have the image names resolved, after a deployment is pushed
+type rolloutWatch struct {
+	targets []rolloutTarget
+	// path is the file changed by the commit, which tells the GitOps resource applying it
+	path    string
+	commit  string
+	tags    []imageTag
+	timeout time.Duration
+	// rollback restores the previous image tags if the rollout fails, which fails as soon as a pod is in CrashLoopBackOff. It's nil without auto-rollback
+	rollback func() (messages []string, err error)
+}
+
+// run waits until the GitOps engine applies the commit and the targets are rolled out, or the timeout is reached. A failed rollout is summarized with the reasons of the engine or the pods, followed by the rollback if there is one
+func (w rolloutWatch) run() (messages []string, err error) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), w.timeout)
+	defer cancelFn()
+
+	images := make(map[string]string)
+	for _, t := range w.tags {
+		images[t.image] = t.tag
+	}
+	var failOn []string
+	if w.rollback != nil {
+		failOn = []string{k8sop.ReasonCrashLoopBackOff}
+	}
+
+	start := time.Now()
+	messages, failures := w.waitForSync(ctx, start)
+	for _, t := range w.targets {
+		// the deployments won't roll out if the commit isn't applied
+		if len(failures) != 0 {
+			break
+		}
+		rollout, err := t.cluster.WaitForRollout(ctx, t.service, images, rolloutInterval, failOn...)
+		switch {
+		case err == nil:
+			messages = append(messages, fmt.Sprintf("%s(%s/%s) rolled out in %s", t.service, t.project, t.stage, time.Since(start).Round(time.Second)))
+			continue
+		case errors.Is(err, context.DeadlineExceeded):
+			failures = append(failures, fmt.Sprintf("%s(%s/%s) didn't roll out in %s: %s", t.service, t.project, t.stage, w.timeout, rollout))
+		case errors.Is(err, k8sop.ErrRolloutFailed):
+			failures = append(failures, fmt.Sprintf("%s(%s/%s) failed to roll out: %s", t.service, t.project, t.stage, rollout))
+		default:
+			failures = append(failures, fmt.Sprintf("checking the rollout of %s(%s/%s) has error: %s", t.service, t.project, t.stage, err))
+		}
+		// the others don't matter once it's rolled back
+		if w.rollback != nil {
+			break
+		}
+	}
+	if len(failures) == 0 {
+		return messages, nil
+	}
+
+	if w.rollback != nil {
+		rollbackMessages, err := w.rollback()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("auto-rollback has error: %s", err))
+		}
+		messages = append(messages, rollbackMessages...)
+	}
+	return messages, errors.New(strings.Join(failures, "\n"))
+}
+
+// waitForSync waits for the GitOps engines to apply the commit on the clusters of the targets. Clusters without their engine are reported and skipped
+func (w rolloutWatch) waitForSync(ctx context.Context, start time.Time) (messages, failures []string) {
+	if w.commit == "" {
+		return nil, nil
+	}
+	seen := make(map[*k8sop.Cluster]bool)
+	for _, t := range w.targets {
+		if seen[t.cluster] {
+			continue
+		}
+		seen[t.cluster] = true
+		sync, err := t.cluster.WaitForSync(ctx, w.path, w.commit, rolloutInterval)
+		switch {
+		case err == nil:
+			messages = append(messages, fmt.Sprintf("%s %s applied %s in %s", t.cluster.GitOpsName(), sync.Resource, shortHash(w.commit), time.Since(start).Round(time.Second)))
+		case errors.Is(err, k8sop.ErrSyncFailed):
+			failures = append(failures, fmt.Sprintf("%s failed to apply %s for %s(%s/%s): %s", t.cluster.GitOpsName(), shortHash(w.commit), t.service, t.project, t.stage, sync))
+		case errors.Is(err, context.DeadlineExceeded):
+			failures = append(failures, fmt.Sprintf("%s didn't apply %s for %s(%s/%s) in %s: %s", t.cluster.GitOpsName(), shortHash(w.commit), t.service, t.project, t.stage, w.timeout, sync))
+		default:
+			messages = append(messages, fmt.Sprintf("%s sync status of %s(%s/%s) is unavailable: %s", t.cluster.GitOpsName(), t.service, t.project, t.stage, err))
+		}
+	}
+	return messages, failures
+}
+
+// shortHash abbreviates the commit hash as git does
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// autoRollbackCaller is the caller of the rollbacks made by Major Tom itself
+const autoRollbackCaller = "auto-rollback"
+
+// projectTargets returns the targets in the project
+func projectTargets(targets []rolloutTarget, project string) []rolloutTarget {
+	var inProject []rolloutTarget
+	for _, t := range targets {
+		if t.project == project {
+			inProject = append(inProject, t)
+		}
+	}
+	return inProject
+}
+
+// watchRollouts runs the watches after the command has responded, and posts their results as the follow-up if there is one
+func watchRollouts(followUp FollowUp, message string, watches []rolloutWatch) {
+	if followUp != nil {
+		followUp.Expect()
+	}
+	go func() {
+		results := make([]struct {
+			messages []string
+			err      error
+		}, len(watches))
+		var wg sync.WaitGroup
+		for i, w := range watches {
+			i, w := i, w
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i].messages, results[i].err = w.run()
+			}()
+		}
+		wg.Wait()
+
+		var messages, failures []string
+		for _, r := range results {
+			messages = append(messages, r.messages...)
+			if r.err != nil {
+				failures = append(failures, r.err.Error())
+			}
+		}
+		var err error
+		if len(failures) != 0 {
+			err = errors.New(strings.Join(failures, "\n"))
+		}
+		if followUp == nil {
+			logrus.Infof("rollout of \"%s\": %v, %v", message, messages, err)
+			return
+		}
+		followUp.Post(messages, err)
+	}()
+}
+
+// readPreviousTags reads the image tags at path which are going to be replaced by tags
+func readPreviousTags(repo *gitop.Repository, path string, codebase config.Codebase, tags []imageTag) []imageTag {
+	previous := make([]imageTag, len(tags))
+	for i, t := range tags {
+		previous[i].image = t.image
+		// an image without a previous tag can't be rolled back, which is told by the rollback
+		previous[i].tag, _ = readImageTag(repo, path, codebase, t.image)
+	}
+	return previous
+}
+
+// autoRollback restores the previous image tags of the deployment at path in the project. It's refused if the image tags have been changed since, so a newer deployment won't be reverted
+func autoRollback(codebase *config.Codebase, stage, project, path string, deployed, previous []imageTag, message string) (messages []string, err error) {
+	var titleProject string
+	if project != "" {
+		titleProject = "/" + project
+	}
+	return queue(context.Background(), Deployment{
+		codebase:  codebase,
+		project:   project,
+		stage:     stage,
+		imageTags: previous,
+		caller:    autoRollbackCaller,
+		message:   message,
+		// the deployment being rolled back was allowed in spite of the freezes as well
+		override: true,
+		title:    fmt.Sprintf("rollback(%s/%s%s): rolled back by Major Tom (%s)", codebase.Repo, stage, titleProject, autoRollbackCaller),
+		plan: func(repo *gitop.Repository) ([]fileEdit, error) {
+			var edits []fileEdit
+			for i, t := range previous {
+				t := t
+				current, err := readImageTag(repo, path, *codebase, t.image)
+				if err != nil {
+					return nil, err
+				}
+				if t.tag == "" {
+					return nil, errors.Errorf("previous image tag of %s is unknown", path)
+				}
+				if current != deployed[i].tag {
+					return nil, errors.Errorf("image tag of %s is changed to %s since, so it's not rolled back", path, current)
+				}
+				edit, err := imageTagEdit(*codebase, stage, project, t.image, t.tag)
+				if err != nil {
+					return nil, err
+				}
+				setTag := edit.edit
+				edit.edit = func(doc *yamlop.Document) (changes []string, err error) {
+					changes, err = setTag(doc)
+					if err != nil {
+						return nil, err
+					}
+					return append(changes, fmt.Sprintf("Roll back from %s to %s as the rollout failed", current, t.tag)), nil
+				}
+				edits = append(edits, edit)
+			}
+			return edits, nil
+		},
+	})
+}
@@ -0,0 +1,180 @@
+package command
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	mjcontext "github.com/mirror-media/major-tom-go/v2/internal/context"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_rolloutWatch_run(t *testing.T) {
+	replicas := int32(2)
+	newDeployment := func(name string, available int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &v1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Image: "gcr.io/mirrormedia-1470651750304/openwarehouse:dev_1bac23"}},
+					},
+				},
+			},
+			Status: appsv1.DeploymentStatus{UpdatedReplicas: 2, AvailableReplicas: available},
+		}
+	}
+	cluster := &k8sop.Cluster{
+		Clientset: fake.NewSimpleClientset(
+			newDeployment("openwarehouse-tv-cms", 2),
+			newDeployment("openwarehouse-tv-gql", 1),
+			newDeployment("openwarehouse-tv-worker", 1),
+			&corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{Name: "openwarehouse-tv-worker-6d4b9", Namespace: "default", Labels: map[string]string{"app": "openwarehouse-tv-worker"}},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "worker",
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					}},
+				},
+			},
+		),
+		Namespace: "default",
+	}
+	tags := []imageTag{{image: "gcr.io/mirrormedia-1470651750304/openwarehouse", tag: "dev_1bac23"}}
+
+	cms := rolloutTarget{service: "openwarehouse-tv-cms", project: "tv", stage: "dev", cluster: cluster}
+	gql := rolloutTarget{service: "openwarehouse-tv-gql", project: "tv", stage: "dev", cluster: cluster}
+	crashing := rolloutTarget{service: "openwarehouse-tv-worker", project: "tv", stage: "dev", cluster: cluster}
+
+	messages, err := rolloutWatch{targets: []rolloutTarget{cms}, tags: tags, timeout: time.Second}.run()
+	if err != nil {
+		t.Fatalf("rolloutWatch.run() error = %v", err)
+	}
+	if len(messages) != 1 || !strings.HasPrefix(messages[0], "openwarehouse-tv-cms(tv/dev) rolled out in ") {
+		t.Errorf("rolloutWatch.run() = %v", messages)
+	}
+
+	// the cluster has no GitOps engine
+	messages, err = rolloutWatch{targets: []rolloutTarget{cms}, path: "openwarehouse/overlays/dev/kustomization.yaml", commit: "1bac23f9c0", tags: tags, timeout: time.Second}.run()
+	if err != nil || len(messages) != 2 || !strings.HasPrefix(messages[0], "GitOps sync status of openwarehouse-tv-cms(tv/dev) is unavailable") {
+		t.Errorf("rolloutWatch.run() = %v, %v", messages, err)
+	}
+
+	_, err = rolloutWatch{targets: []rolloutTarget{gql}, tags: tags, timeout: 10 * time.Millisecond}.run()
+	want := "openwarehouse-tv-gql(tv/dev) didn't roll out in 10ms: 2/2 updated, 1/2 available"
+	if err == nil || err.Error() != want {
+		t.Errorf("rolloutWatch.run() error = %v, want %v", err, want)
+	}
+
+	// a pod in CrashLoopBackOff is rolled back before the timeout
+	var isRolledBack bool
+	messages, err = rolloutWatch{targets: []rolloutTarget{crashing, cms}, tags: tags, timeout: time.Minute, rollback: func() ([]string, error) {
+		isRolledBack = true
+		return []string{"rolled back"}, nil
+	}}.run()
+	want = "openwarehouse-tv-worker(tv/dev) failed to roll out: 2/2 updated, 1/2 available; pod(openwarehouse-tv-worker-6d4b9) container(worker): CrashLoopBackOff"
+	if err == nil || err.Error() != want {
+		t.Errorf("rolloutWatch.run() error = %v, want %v", err, want)
+	}
+	if !isRolledBack || len(messages) != 1 || messages[0] != "rolled back" {
+		t.Errorf("rolloutWatch.run() = %v, rolled back %v", messages, isRolledBack)
+	}
+}
+
+func Test_releaseWatches(t *testing.T) {
+	replicas := int32(1)
+	cluster := &k8sop.Cluster{
+		Clientset: fake.NewSimpleClientset(
+			&appsv1.Deployment{
+				ObjectMeta: v1.ObjectMeta{Name: "openwarehouse-tv-worker", Namespace: "default"},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "openwarehouse-tv-worker"}},
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Image: "gcr.io/mirrormedia-1470651750304/mirror-tv-nuxt:prod_1bac23f"}},
+						},
+					},
+				},
+				Status: appsv1.DeploymentStatus{UpdatedReplicas: 1},
+			},
+			&corev1.Pod{
+				ObjectMeta: v1.ObjectMeta{Name: "openwarehouse-tv-worker-6d4b9", Namespace: "default", Labels: map[string]string{"app": "openwarehouse-tv-worker"}},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "worker",
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					}},
+				},
+			},
+		),
+		Namespace: "default",
+	}
+	codebase := &config.Codebase{
+		Type:         2,
+		Repo:         "openwarehouse",
+		Stages:       []string{"dev", "staging", "prod"},
+		Projects:     []string{"tv"},
+		Services:     []string{"worker"},
+		AutoRollback: config.AutoRollback{Enabled: true},
+	}
+	path, err := codebase.GetImageKustomizationPath("prod", "tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newTestRepository(t, []map[string]string{{path: testKustomization("prod_1bac23f")}})
+
+	args := releaseArgs{codebase: codebase, projects: []string{"tv"}, imageTags: []imageTag{{tag: "prod_1bac23f"}}}
+	targets := []rolloutTarget{{service: "openwarehouse-tv-worker", project: "tv", stage: "prod", cluster: cluster}}
+	refs := []imageTag{{image: "gcr.io/mirrormedia-1470651750304/mirror-tv-nuxt", tag: "prod_1bac23f"}}
+	previous := [][]imageTag{{{tag: "prod_81ab7ac"}}}
+	watches := releaseWatches(codebase, args, targets, []string{path}, "", refs, previous, time.Minute, "release openwarehouse project=tv image-tag=prod_1bac23f")
+	if len(watches) != 1 {
+		t.Fatalf("releaseWatches() = %d watches, want 1", len(watches))
+	}
+
+	type result struct {
+		messages []string
+		err      error
+	}
+	done := make(chan result)
+	go func() {
+		messages, err := watches[0].run()
+		done <- result{messages, err}
+	}()
+
+	// the deploy worker isn't running, so the rollback queued is taken here
+	var rollback Deployment
+	select {
+	case rollback = <-deployChannel:
+	case <-time.After(30 * time.Second):
+		t.Fatal("no rollback is queued")
+	}
+	if rollback.stage != "prod" || rollback.project != "tv" || rollback.caller != autoRollbackCaller {
+		t.Errorf("rollback is queued to %s/%s by %s, want prod/tv by %s", rollback.stage, rollback.project, rollback.caller, autoRollbackCaller)
+	}
+	edits, err := rollback.plan(repo)
+	if err != nil {
+		t.Fatalf("rollback plan error = %v", err)
+	}
+	if len(edits) != 1 || edits[0].path != path {
+		t.Errorf("rollback plan = %+v, want an edit of %s", edits, path)
+	}
+	rollback.ctx.Value(mjcontext.ResponseChannel).(chan response) <- response{Messages: []string{"rolled back"}}
+
+	r := <-done
+	if r.err == nil || !strings.Contains(r.err.Error(), "CrashLoopBackOff") {
+		t.Errorf("rolloutWatch.run() error = %v, want the rollout failure", r.err)
+	}
+	if len(r.messages) != 1 || r.messages[0] != "rolled back" {
+		t.Errorf("rolloutWatch.run() = %v, want the rollback", r.messages)
+	}
+}
@@ -0,0 +1,211 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/gitop"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxReplicasKey = "spec.maxReplicas"
+	minReplicasKey = "spec.minReplicas"
+)
+
+// ScalePlan describes the autoscaling configurations a scale is going to replace. Replicas of 0 are left untouched
+type ScalePlan struct {
+	Repo                string
+	Service             string
+	Stage               string
+	MaxReplicas         int
+	MinReplicas         int
+	DeployedMaxReplicas int
+	DeployedMinReplicas int
+	DryRun              bool
+}
+
+type scaleArgs struct {
+	codebase    *config.Codebase
+	service     *config.Service
+	stage       string
+	maxReplicas int
+	minReplicas int
+	dryRun      bool
+	override    bool
+}
+
+// Scale changes the autoscaling configurations of a service. texts is interpreted as [service, env=value, maxReplicas=value, minReplicas=value]
+func Scale(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []string, message, caller string) (messages []string, err error) {
+	if !DeployWorker.isRunning {
+		return nil, errors.New("deploy worker is not running")
+	}
+
+	args, err := parseScale(k8sRepo, texts)
+	if err != nil {
+		return nil, err
+	}
+	codebase, service, stage := args.codebase, args.service, args.stage
+
+	edit, err := hpaEdit(*codebase, *service, stage, args.maxReplicas, args.minReplicas)
+	if err != nil {
+		return nil, err
+	}
+
+	return queue(ctx, Deployment{
+		codebase: codebase,
+		service:  service,
+		project:  service.Project,
+		stage:    stage,
+		caller:   caller,
+		message:  message,
+		title:    fmt.Sprintf("scale(%s/%s): scaled by %s", service.Name, stage, caller),
+		edits:    []fileEdit{edit},
+		dryRun:   args.dryRun,
+		override: args.override,
+	})
+}
+
+// PlanScale reads the autoscaling configurations which the scale would replace. texts is interpreted as Scale does
+func PlanScale(ctx context.Context, k8sRepo config.KubernetesConfigsRepo, texts []string, message string) (plan ScalePlan, err error) {
+	if !DeployWorker.isRunning {
+		return plan, errors.New("deploy worker is not running")
+	}
+
+	args, err := parseScale(k8sRepo, texts)
+	if err != nil {
+		return plan, err
+	}
+	path, err := args.codebase.GetHpaPath(args.stage, args.service.Project, args.service.SimpleService)
+	if err != nil {
+		return plan, err
+	}
+
+	plan = ScalePlan{
+		Repo:        args.codebase.Repo,
+		Service:     args.service.Name,
+		Stage:       args.stage,
+		MaxReplicas: args.maxReplicas,
+		MinReplicas: args.minReplicas,
+		DryRun:      args.dryRun,
+	}
+	_, err = ask(ctx, message, func(repo *gitop.Repository) (messages []string, err error) {
+		var hasHPA bool
+		hasHPA, plan.DeployedMaxReplicas, plan.DeployedMinReplicas, err = readReplicas(repo, path)
+		if err == nil && !hasHPA {
+			err = errors.New("auto scaling is not support for the service")
+		}
+		return nil, err
+	})
+	return plan, err
+}
+
+func parseScale(k8sRepo config.KubernetesConfigsRepo, texts []string) (args scaleArgs, err error) {
+	if len(texts) < 1 {
+		return args, errors.New("call help")
+	}
+
+	// scale finds the codebase by the service name because the naming convention of kubernetes-configs
+	serviceNameInCMD, texts := pop(texts, 0)
+	args.codebase, args.service, err = findService(k8sRepo.Configs, serviceNameInCMD)
+	if err != nil {
+		return args, err
+	}
+
+	texts, args.stage, err = popValue(texts, "env", "=")
+	if err != nil {
+		return args, errors.Wrap(err, "getting env for scaling encountered an error")
+	}
+
+	texts, args.maxReplicas, err = popReplicas(texts, "maxReplicas")
+	if err != nil {
+		return args, err
+	}
+	texts, args.minReplicas, err = popReplicas(texts, "minReplicas")
+	if err != nil {
+		return args, err
+	}
+	if args.maxReplicas == 0 && args.minReplicas == 0 {
+		return args, errors.New("either maxReplicas or minReplicas is expected")
+	} else if args.maxReplicas != 0 && args.minReplicas > args.maxReplicas {
+		return args, errors.Errorf("minReplicas(%d) cannot be greater than maxReplicas(%d)", args.minReplicas, args.maxReplicas)
+	}
+
+	texts, args.dryRun, err = popDryRun(texts)
+	if err != nil {
+		return args, err
+	}
+	texts, args.override, err = popOverride(texts)
+	if err != nil {
+		return args, err
+	}
+
+	if len(texts) != 0 {
+		return args, errors.New("Major Tom does not support: " + strings.Join(texts, ", "))
+	}
+	return args, nil
+}
+
+// popReplicas pops the replicas argument. 0 is returned if the argument is not supplied
+func popReplicas(texts []string, arg string) (newTexts []string, replicas int, err error) {
+	newTexts, value, errPop := popValue(texts, arg, "=")
+	if errPop != nil && len(newTexts) == len(texts) {
+		return newTexts, 0, nil
+	}
+	if errPop != nil {
+		return newTexts, 0, errPop
+	}
+	replicas, err = strconv.Atoi(value)
+	if err != nil || replicas < 1 {
+		return newTexts, 0, errors.Errorf("%s(%s) should be a positive number", arg, value)
+	}
+	return newTexts, replicas, nil
+}
+
+// hpaEdit sets the replicas of hpa.yaml of the service. Replicas of 0 are left untouched
+func hpaEdit(codebase config.Codebase, service config.Service, stage string, maxReplicas, minReplicas int) (fileEdit, error) {
+	path, err := codebase.GetHpaPath(stage, service.Project, service.SimpleService)
+	if err != nil {
+		return fileEdit{}, err
+	}
+	return fileEdit{
+		path:    path,
+		missing: errors.New("auto scaling is not support for the service"),
+		edit: func(doc *yamlop.Document) (changes []string, err error) {
+			for _, r := range []struct {
+				name     string
+				key      string
+				replicas int
+			}{
+				{name: "maxReplicas", key: maxReplicasKey, replicas: maxReplicas},
+				{name: "minReplicas", key: minReplicasKey, replicas: minReplicas},
+			} {
+				if r.replicas == 0 {
+					continue
+				}
+				deployed, _ := doc.Get(r.key)
+				err = doc.Set(r.key, r.replicas)
+				if err != nil {
+					return nil, errors.Wrap(err, fmt.Sprintf("fail to set %s in %s", r.name, path))
+				}
+				if deployed == "" {
+					changes = append(changes, fmt.Sprintf("Set %s(%s) to %d", r.name, r.key, r.replicas))
+				} else {
+					changes = append(changes, fmt.Sprintf("Set %s(%s) from %s to %d", r.name, r.key, deployed, r.replicas))
+				}
+			}
+
+			// the unchanged one in the file still needs to be consistent with the new one
+			// minReplicas defaults to 1 in Kubernetes
+			newMax, newMin := getInt(doc, maxReplicasKey, 0), getInt(doc, minReplicasKey, 1)
+			if newMin > newMax {
+				return nil, errors.Errorf("minReplicas(%d) cannot be greater than maxReplicas(%d) in %s", newMin, newMax, path)
+			}
+			return changes, nil
+		},
+	}, nil
+}
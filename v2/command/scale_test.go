@@ -0,0 +1,89 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/internal/test"
+	"github.com/mirror-media/major-tom-go/v2/yamlop"
+)
+
+const hpaYAML = `apiVersion: autoscaling/v1
+kind: HorizontalPodAutoscaler
+metadata:
+  name: openwarehouse-tv-gql-external
+spec:
+  maxReplicas: 4
+  minReplicas: 2
+  targetCPUUtilizationPercentage: 80
+`
+
+func Test_hpaEdit(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxReplicas int
+		minReplicas int
+		wantChanges []string
+		wantMax     int
+		wantMin     int
+		wantErr     bool
+	}{
+		{
+			name:        "set both",
+			maxReplicas: 6,
+			minReplicas: 3,
+			wantChanges: []string{"Set maxReplicas(spec.maxReplicas) from 4 to 6", "Set minReplicas(spec.minReplicas) from 2 to 3"},
+			wantMax:     6,
+			wantMin:     3,
+		},
+		{
+			name:        "set maxReplicas only",
+			maxReplicas: 5,
+			wantChanges: []string{"Set maxReplicas(spec.maxReplicas) from 4 to 5"},
+			wantMax:     5,
+			wantMin:     2,
+		},
+		{
+			name:        "minReplicas greater than the existing maxReplicas",
+			minReplicas: 5,
+			wantErr:     true,
+		},
+	}
+	codebase, service, err := findService(test.K8sRepo.Configs, "openwarehouse-tv-gql-external")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := hpaEdit(*codebase, *service, "prod", tt.maxReplicas, tt.minReplicas)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "openwarehouse/overlays/prod/overlays/tv/overlays/gql-external/hpa.yaml"; e.path != want {
+				t.Errorf("hpaEdit() path = %v, want %v", e.path, want)
+			}
+
+			doc, err := yamlop.Parse([]byte(hpaYAML))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotChanges, err := e.edit(doc)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("hpaEdit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(gotChanges, tt.wantChanges) {
+				t.Errorf("hpaEdit() changes = %v, want %v", gotChanges, tt.wantChanges)
+			}
+			if got := getInt(doc, maxReplicasKey, 0); got != tt.wantMax {
+				t.Errorf("maxReplicas = %v, want %v", got, tt.wantMax)
+			}
+			if got := getInt(doc, minReplicasKey, 0); got != tt.wantMin {
+				t.Errorf("minReplicas = %v, want %v", got, tt.wantMin)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultNamespace = "default"
+
+// DefaultFluxNamespace is where Flux v2 is installed by default
+const DefaultFluxNamespace = "flux-system"
+
+// DefaultArgoCDNamespace is where Argo CD is installed by default
+const DefaultArgoCDNamespace = "argocd"
+
+// GitOps engines syncing kubernetes-configs to the clusters
+const (
+	GitOpsFlux   = "flux"
+	GitOpsArgoCD = "argocd"
+)
+
+// Cluster is where the services of a project, or of a repo, are running in a stage. Repo takes precedence over Project when both match
+type Cluster struct {
+	Project        string `yaml:"project"`
+	Repo           string `yaml:"repo"`
+	Stage          string `yaml:"stage"`
+	KubeConfigPath string `yaml:"kubeConfigPath"`
+	// Context is the context in the kubeconfig. The current context is used if it's empty
+	Context string `yaml:"context"`
+	// Namespace is where the deployments are. DefaultNamespace is used if it's empty
+	Namespace string `yaml:"namespace"`
+	// FluxNamespace is where the Flux v2 kustomizations are. DefaultFluxNamespace is used if it's empty
+	FluxNamespace string `yaml:"fluxNamespace"`
+	// GitOps is the engine syncing kubernetes-configs to the cluster, which is GitOpsFlux or GitOpsArgoCD. GitOpsFlux is used if it's empty
+	GitOps string `yaml:"gitOps"`
+	// ArgoCDNamespace is where the Argo CD applications are. DefaultArgoCDNamespace is used if it's empty
+	ArgoCDNamespace string `yaml:"argoCDNamespace"`
+}
+
+// GetFluxNamespace returns FluxNamespace or DefaultFluxNamespace
+func (c Cluster) GetFluxNamespace() string {
+	if c.FluxNamespace == "" {
+		return DefaultFluxNamespace
+	}
+	return c.FluxNamespace
+}
+
+// GetGitOps returns GitOps or GitOpsFlux
+func (c Cluster) GetGitOps() string {
+	if c.GitOps == "" {
+		return GitOpsFlux
+	}
+	return c.GitOps
+}
+
+// GetArgoCDNamespace returns ArgoCDNamespace or DefaultArgoCDNamespace
+func (c Cluster) GetArgoCDNamespace() string {
+	if c.ArgoCDNamespace == "" {
+		return DefaultArgoCDNamespace
+	}
+	return c.ArgoCDNamespace
+}
+
+func (c Cluster) String() string {
+	if c.Repo != "" {
+		return fmt.Sprintf("repo(%s)/stage(%s)", c.Repo, c.Stage)
+	}
+	return fmt.Sprintf("project(%s)/stage(%s)", c.Project, c.Stage)
+}
+
+type Clusters []Cluster
+
+// Validate checks every cluster is complete and no two clusters are defined for the same target
+func (clusters Clusters) Validate() error {
+	found := make(map[string]bool, len(clusters))
+	for i, c := range clusters {
+		switch {
+		case c.Stage == "":
+			return errors.Errorf("clusters[%d] has no stage", i)
+		case c.Project == "" && c.Repo == "":
+			return errors.Errorf("clusters[%d] has neither project nor repo", i)
+		case c.Project != "" && c.Repo != "":
+			return errors.Errorf("clusters[%d] can't have both project and repo", i)
+		case c.KubeConfigPath == "":
+			return errors.Errorf("%s has no kubeConfigPath", c)
+		case c.GetGitOps() != GitOpsFlux && c.GetGitOps() != GitOpsArgoCD:
+			return errors.Errorf("%s has an unknown gitOps(%s)", c, c.GitOps)
+		case found[c.String()]:
+			return errors.Errorf("%s is defined more than once", c)
+		}
+		found[c.String()] = true
+	}
+	return nil
+}
+
+// Get returns the cluster of the repo in the stage, or the cluster of the project in the stage
+func (clusters Clusters) Get(repo, project, stage string) (cluster Cluster, err error) {
+	var isFound bool
+	for _, c := range clusters {
+		if c.Stage != stage {
+			continue
+		}
+		if c.Repo != "" && c.Repo == repo {
+			cluster, isFound = c, true
+			break
+		}
+		if c.Project != "" && c.Project == project {
+			cluster, isFound = c, true
+		}
+	}
+	if !isFound {
+		return cluster, errors.Errorf("no cluster is configured for %s in project(%s) and stage(%s)", repo, project, stage)
+	}
+	if cluster.Namespace == "" {
+		cluster.Namespace = DefaultNamespace
+	}
+	return cluster, nil
+}
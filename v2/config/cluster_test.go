@@ -0,0 +1,125 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusters_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		clusters Clusters
+		wantErr  bool
+	}{
+		{
+			name: "valid clusters",
+			clusters: Clusters{
+				{Project: "tv", Stage: "prod", KubeConfigPath: "/kube/tv-prod"},
+				{Project: "tv", Stage: "dev", KubeConfigPath: "/kube/tv-dev"},
+				{Repo: "mirror-tv-nuxt", Stage: "prod", KubeConfigPath: "/kube/nuxt-prod"},
+			},
+		},
+		{
+			name: "no stage",
+			clusters: Clusters{
+				{Project: "tv", KubeConfigPath: "/kube/tv-prod"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "neither project nor repo",
+			clusters: Clusters{
+				{Stage: "prod", KubeConfigPath: "/kube/tv-prod"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "both project and repo",
+			clusters: Clusters{
+				{Project: "tv", Repo: "mirror-tv-nuxt", Stage: "prod", KubeConfigPath: "/kube/tv-prod"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "no kubeConfigPath",
+			clusters: Clusters{
+				{Project: "tv", Stage: "prod"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown gitOps",
+			clusters: Clusters{
+				{Project: "tv", Stage: "prod", KubeConfigPath: "/kube/tv-prod", GitOps: "fleet"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate",
+			clusters: Clusters{
+				{Project: "tv", Stage: "prod", KubeConfigPath: "/kube/tv-prod"},
+				{Project: "tv", Stage: "prod", KubeConfigPath: "/kube/tv-prod-2"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.clusters.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Clusters.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusters_Get(t *testing.T) {
+	clusters := Clusters{
+		{Repo: "mirror-tv-nuxt", Stage: "prod", KubeConfigPath: "/kube/nuxt-prod", Namespace: "nuxt"},
+		{Project: "tv", Stage: "prod", KubeConfigPath: "/kube/tv-prod", Context: "tv-prod"},
+		{Project: "tv", Stage: "dev", KubeConfigPath: "/kube/tv-dev"},
+	}
+	type args struct {
+		repo    string
+		project string
+		stage   string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    Cluster
+		wantErr bool
+	}{
+		{
+			name: "cluster of the project",
+			args: args{repo: "openwarehouse", project: "tv", stage: "prod"},
+			want: Cluster{Project: "tv", Stage: "prod", KubeConfigPath: "/kube/tv-prod", Context: "tv-prod", Namespace: "default"},
+		},
+		{
+			name: "cluster of the repo takes precedence",
+			args: args{repo: "mirror-tv-nuxt", project: "tv", stage: "prod"},
+			want: Cluster{Repo: "mirror-tv-nuxt", Stage: "prod", KubeConfigPath: "/kube/nuxt-prod", Namespace: "nuxt"},
+		},
+		{
+			name: "repo falls back to the project in other stages",
+			args: args{repo: "mirror-tv-nuxt", project: "tv", stage: "dev"},
+			want: Cluster{Project: "tv", Stage: "dev", KubeConfigPath: "/kube/tv-dev", Namespace: "default"},
+		},
+		{
+			name:    "no cluster",
+			args:    args{repo: "openwarehouse", project: "tv", stage: "staging"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := clusters.Get(tt.args.repo, tt.args.project, tt.args.stage)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Clusters.Get() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Clusters.Get() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,10 +7,8 @@ import (
 	"github.com/pkg/errors"
 )
 
-type KubeConfigPath string
 type Stage string
 type Project string
-type K8S map[Project]map[Stage]KubeConfigPath
 
 type Repository string
 type GitConfig struct {
@@ -22,21 +20,65 @@ type GitConfig struct {
 }
 
 type Config struct {
-	SlackAppToken string `yaml:"slackAppToken"`
-	SlackBotToken string `yaml:"slackBotToken"`
+	Audit         Audit        `yaml:"audit"`
+	Clusters      Clusters     `yaml:"clusters"`
+	Confirmation  Confirmation `yaml:"confirmation"`
+	Freeze        Freeze       `yaml:"freeze"`
+	Metrics       Metrics      `yaml:"metrics"`
+	Policy        Policy       `yaml:"policy"`
+	Registry      Registry     `yaml:"registry"`
+	Rollout       Rollout      `yaml:"rollout"`
+	SlackAppToken string       `yaml:"slackAppToken"`
+	SlackBotToken string       `yaml:"slackBotToken"`
+}
+
+// Audit is where the commands are recorded
+type Audit struct {
+	// File is the JSON lines file of the entries. Commands are not recorded if it's empty
+	File string `yaml:"file"`
+}
+
+const DefaultMetricsAddr = ":8080"
+
+// Metrics is where the metrics and the health checks are served
+type Metrics struct {
+	// Addr is the address of the HTTP server. DefaultMetricsAddr is used if it's empty
+	Addr string `yaml:"addr"`
+}
+
+// GetAddr returns Addr or DefaultMetricsAddr
+func (m Metrics) GetAddr() string {
+	if m.Addr == "" {
+		return DefaultMetricsAddr
+	}
+	return m.Addr
 }
 
 type KubernetesConfigsRepo struct {
 	Git     GitConfig  `yaml:"git"`
 	Configs []Codebase `yaml:"configs"`
+	// TagPolicies are the tag policies of the stages shared by the codebases without their own
+	TagPolicies map[string]TagPolicy `yaml:"tagPolicies"`
 }
 
 type Codebase struct {
+	// AutoRollback restores the previous image tags if a deployment doesn't roll out
+	AutoRollback AutoRollback `yaml:"autoRollback"`
+	// AutoReconcile requests the GitOps engine of the clusters to reconcile right after a deployment is pushed instead of waiting for its interval
+	AutoReconcile bool `yaml:"autoReconcile"`
+	// Images are the names of the kustomize images owned by the repo. The only one is deployed if the command doesn't choose one
+	Images   []string `yaml:"images"`
 	Projects []string `yaml:"projects"`
 	Repo     string   `yaml:"repo"`
 	Services []string `yaml:"services"`
-	Stages   []string `yaml:"stages"`
-	Type     int8     `yaml:"type"`
+	// SkipImageCheck doesn't verify the image tags exist in the registry before they are deployed
+	SkipImageCheck bool `yaml:"skipImageCheck"`
+	// SkipStagingCheck releases the image tags to prod even if they were never on staging
+	SkipStagingCheck bool     `yaml:"skipStagingCheck"`
+	Stages           []string `yaml:"stages"`
+	// TagPolicies restrict the image tags deployed to the stages
+	TagPolicies map[string]TagPolicy `yaml:"tagPolicies"`
+	Type        int8                 `yaml:"type"`
 }
 
 type Service struct {
@@ -55,6 +97,24 @@ func contains(s []string, target string) bool {
 	return false
 }
 
+// HasStage reports whether the codebase is deployed in the stage
+func (c Codebase) HasStage(stage string) bool {
+	return contains(c.Stages, stage)
+}
+
+// HasProject reports whether the codebase is deployed for the project. Type 1 codebases without projects are shared by all projects
+func (c Codebase) HasProject(project string) bool {
+	if c.Type == 1 && len(c.Projects) == 0 {
+		return true
+	}
+	return contains(c.Projects, project)
+}
+
+// HasImage reports whether the codebase owns the image. Codebases without images are not restricted
+func (c Codebase) HasImage(image string) bool {
+	return len(c.Images) == 0 || contains(c.Images, image)
+}
+
 func (c Codebase) GetServices() (services []Service, err error) {
 	switch c.Type {
 	case 1:
@@ -67,6 +127,7 @@ func (c Codebase) GetServices() (services []Service, err error) {
 			for _, service := range c.Services {
 				services = append(services, Service{
 					Name:          fmt.Sprintf("%s-%s-%s", c.Repo, project, service),
+					Project:       project,
 					Repo:          c.Repo,
 					SimpleService: service,
 				})
@@ -93,21 +93,25 @@ func TestCodebase_GetServices(t *testing.T) {
 			wantServices: []Service{
 				{
 					Name:          "repoXYZ-p1-s1",
+					Project:       "p1",
 					Repo:          "repoXYZ",
 					SimpleService: "s1",
 				},
 				{
 					Name:          "repoXYZ-p1-s2",
+					Project:       "p1",
 					Repo:          "repoXYZ",
 					SimpleService: "s2",
 				},
 				{
 					Name:          "repoXYZ-p2-s1",
+					Project:       "p2",
 					Repo:          "repoXYZ",
 					SimpleService: "s1",
 				},
 				{
 					Name:          "repoXYZ-p2-s2",
+					Project:       "p2",
 					Repo:          "repoXYZ",
 					SimpleService: "s2",
 				},
@@ -124,21 +128,25 @@ func TestCodebase_GetServices(t *testing.T) {
 			wantServices: []Service{
 				{
 					Name:          "repoXYZ-p1-s1",
+					Project:       "p1",
 					Repo:          "repoXYZ",
 					SimpleService: "s1",
 				},
 				{
 					Name:          "repoXYZ-p1-s2",
+					Project:       "p1",
 					Repo:          "repoXYZ",
 					SimpleService: "s2",
 				},
 				{
 					Name:          "repoXYZ-p2-s1",
+					Project:       "p2",
 					Repo:          "repoXYZ",
 					SimpleService: "s1",
 				},
 				{
 					Name:          "repoXYZ-p2-s2",
+					Project:       "p2",
 					Repo:          "repoXYZ",
 					SimpleService: "s2",
 				},
@@ -156,21 +164,25 @@ func TestCodebase_GetServices(t *testing.T) {
 			wantServices: []Service{
 				{
 					Name:          "repoXYZ-p1-s1",
+					Project:       "p1",
 					Repo:          "repoXYZ",
 					SimpleService: "s1",
 				},
 				{
 					Name:          "repoXYZ-p1-s2",
+					Project:       "p1",
 					Repo:          "repoXYZ",
 					SimpleService: "s2",
 				},
 				{
 					Name:          "repoXYZ-p2-s1",
+					Project:       "p2",
 					Repo:          "repoXYZ",
 					SimpleService: "s1",
 				},
 				{
 					Name:          "repoXYZ-p2-s2",
+					Project:       "p2",
 					Repo:          "repoXYZ",
 					SimpleService: "s2",
 				},
@@ -589,3 +601,63 @@ func TestCodebase_GetImageKustomizationPath(t *testing.T) {
 		})
 	}
 }
+
+func TestCodebase_HasProject(t *testing.T) {
+	tests := []struct {
+		name     string
+		codebase Codebase
+		project  string
+		want     bool
+	}{
+		{
+			name: "type 2 with the project",
+			codebase: Codebase{
+				Type:     2,
+				Projects: []string{"p1", "p2"},
+			},
+			project: "p1",
+			want:    true,
+		},
+		{
+			name: "type 2 without the project",
+			codebase: Codebase{
+				Type:     2,
+				Projects: []string{"p1", "p2"},
+			},
+			project: "p3",
+			want:    false,
+		},
+		{
+			name: "type 2 without projects has no project",
+			codebase: Codebase{
+				Type: 2,
+			},
+			project: "p1",
+			want:    false,
+		},
+		{
+			name: "type 1 without projects is shared by all projects",
+			codebase: Codebase{
+				Type: 1,
+			},
+			project: "p1",
+			want:    true,
+		},
+		{
+			name: "type 1 with projects is limited to them",
+			codebase: Codebase{
+				Type:     1,
+				Projects: []string{"p2"},
+			},
+			project: "p1",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.codebase.HasProject(tt.project); got != tt.want {
+				t.Errorf("Codebase.HasProject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
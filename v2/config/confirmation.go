@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultConfirmationTimeout = 5 * time.Minute
+
+// Confirmation is about who can confirm a prod change and how long the change waits for it
+type Confirmation struct {
+	// ApproverGroup is the group of the policy whose users are allowed to confirm a change besides its caller
+	ApproverGroup string `yaml:"approverGroup"`
+	// TwoPerson requires prod releases and scales to be approved by an approver other than the caller. Other changes, like prod promotions and rollbacks, are confirmed as usual
+	TwoPerson bool `yaml:"twoPerson"`
+	// Timeout is a duration like 10m. DefaultConfirmationTimeout is used if it's empty
+	Timeout string `yaml:"timeout"`
+}
+
+// GetTimeout parses Timeout, which must be positive
+func (c Confirmation) GetTimeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return DefaultConfirmationTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("confirmation timeout(%s) is invalid", c.Timeout))
+	}
+	if timeout <= 0 {
+		return 0, errors.Errorf("confirmation timeout(%s) should be positive", c.Timeout)
+	}
+	return timeout, nil
+}
+
+// GetApprovers resolves the user IDs of ApproverGroup in the groups of the policy. The group is required if two persons are
+func (c Confirmation) GetApprovers(policy Policy) ([]string, error) {
+	if c.ApproverGroup == "" {
+		if c.TwoPerson {
+			return nil, errors.New("confirmation approverGroup is required by twoPerson")
+		}
+		return nil, nil
+	}
+	approvers, ok := policy.Groups[c.ApproverGroup]
+	if !ok {
+		return nil, errors.Errorf("confirmation approverGroup(%s) is not a group of the policy", c.ApproverGroup)
+	}
+	return approvers, nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfirmation_GetTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: DefaultConfirmationTimeout,
+		},
+		{
+			name:    "duration",
+			timeout: "90s",
+			want:    90 * time.Second,
+		},
+		{
+			name:    "not a duration",
+			timeout: "ten minutes",
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			timeout: "-1m",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Confirmation{Timeout: tt.timeout}.GetTimeout()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Confirmation.GetTimeout() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Confirmation.GetTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmation_GetApprovers(t *testing.T) {
+	policy := Policy{Groups: map[string][]string{"sre": {"U-sre1", "U-sre2"}}}
+	tests := []struct {
+		name         string
+		confirmation Confirmation
+		want         []string
+		wantErr      bool
+	}{
+		{
+			name: "no approvers",
+		},
+		{
+			name:         "group of the policy",
+			confirmation: Confirmation{ApproverGroup: "sre", TwoPerson: true},
+			want:         []string{"U-sre1", "U-sre2"},
+		},
+		{
+			name:         "undefined group",
+			confirmation: Confirmation{ApproverGroup: "dev"},
+			wantErr:      true,
+		},
+		{
+			name:         "two persons without approvers",
+			confirmation: Confirmation{TwoPerson: true},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.confirmation.GetApprovers(policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Confirmation.GetApprovers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Confirmation.GetApprovers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+// Freeze is about when the changes are blocked
+type Freeze struct {
+	// StateFile keeps the freezes by the freeze command across restarts. They're kept in memory only if it's empty
+	StateFile string         `yaml:"stateFile"`
+	Windows   []FreezeWindow `yaml:"windows"`
+}
+
+// FreezeWindow blocks the changes of a project in a stage during a date range or the minutes matching a schedule. An empty project or stage matches all
+type FreezeWindow struct {
+	Project string `yaml:"project"`
+	Stage   string `yaml:"stage"`
+	Reason  string `yaml:"reason"`
+	// From and To are RFC3339 times of the date range
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	// Schedule is a cron expression of "minute hour day-of-month month day-of-week" matching the frozen minutes, e.g. "* * * * 0,6" for weekends
+	Schedule string `yaml:"schedule"`
+	// TimeZone is the IANA time zone of Schedule, e.g. Asia/Taipei. The local time zone is used if it's empty
+	TimeZone string `yaml:"timeZone"`
+}
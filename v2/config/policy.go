@@ -0,0 +1,90 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Policy allows the commands by the rules. Every command is allowed if there is no rule
+type Policy struct {
+	// Groups are named lists of Slack user IDs to be referred by the rules
+	Groups map[string][]string `yaml:"groups"`
+	Rules  []Rule              `yaml:"rules"`
+}
+
+// Rule allows the verbs on the repos in the stages for the users in the channels. An empty list of users, groups, channels, repos or stages matches all
+type Rule struct {
+	Users    []string `yaml:"users"`
+	Groups   []string `yaml:"groups"`
+	Channels []string `yaml:"channels"`
+	Verbs    []string `yaml:"verbs"`
+	Repos    []string `yaml:"repos"`
+	Stages   []string `yaml:"stages"`
+}
+
+// Action is a command of a user in a channel to be authorized. Repo and Stage are empty if the command doesn't target any
+type Action struct {
+	UserID    string
+	ChannelID string
+	Verb      string
+	Repo      string
+	Stage     string
+}
+
+func (a Action) String() string {
+	s := a.Verb
+	if a.Repo != "" {
+		s += " " + a.Repo
+	}
+	if a.Stage != "" {
+		s += " in " + a.Stage
+	}
+	return s
+}
+
+// Validate checks every rule has verbs and refers to defined groups only
+func (p Policy) Validate() error {
+	for i, r := range p.Rules {
+		if len(r.Verbs) == 0 {
+			return errors.Errorf("policy rules[%d] has no verbs", i)
+		}
+		for _, g := range r.Groups {
+			if _, ok := p.Groups[g]; !ok {
+				return errors.Errorf("policy rules[%d] refers to undefined group(%s)", i, g)
+			}
+		}
+	}
+	return nil
+}
+
+// Authorize returns an error telling why the action is denied if no rule allows it
+func (p Policy) Authorize(a Action) error {
+	if len(p.Rules) == 0 {
+		return nil
+	}
+	for _, r := range p.Rules {
+		if p.isUserOf(r, a.UserID) && matches(r.Channels, a.ChannelID) && contains(r.Verbs, a.Verb) && matches(r.Repos, a.Repo) && matches(r.Stages, a.Stage) {
+			return nil
+		}
+	}
+	return errors.Errorf("permission denied: you are not allowed to %s from this channel", a)
+}
+
+func (p Policy) isUserOf(r Rule, userID string) bool {
+	if len(r.Users) == 0 && len(r.Groups) == 0 {
+		return true
+	}
+	if contains(r.Users, userID) {
+		return true
+	}
+	for _, g := range r.Groups {
+		if contains(p.Groups[g], userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the value is in the list, or the list is empty. An empty value only matches an empty list
+func matches(list []string, value string) bool {
+	return len(list) == 0 || contains(list, value)
+}
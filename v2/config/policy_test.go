@@ -0,0 +1,112 @@
+package config
+
+import "testing"
+
+var testPolicy = Policy{
+	Groups: map[string][]string{
+		"interns": {"U-intern"},
+		"sre":     {"U-sre"},
+	},
+	Rules: []Rule{
+		{Groups: []string{"interns"}, Verbs: []string{"deploy"}, Stages: []string{"dev"}},
+		{Groups: []string{"sre"}, Verbs: []string{"deploy", "scale"}},
+		{Groups: []string{"sre"}, Channels: []string{"C-release"}, Verbs: []string{"release"}, Stages: []string{"prod"}},
+		{Users: []string{"U-nuxt"}, Verbs: []string{"deploy"}, Repos: []string{"mirror-tv-nuxt"}},
+		{Verbs: []string{"list", "info"}},
+	},
+}
+
+func TestPolicy_Authorize(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		action  Action
+		wantErr bool
+	}{
+		{
+			name:   "no rule allows everything",
+			action: Action{UserID: "U-anyone", Verb: "release", Repo: "openwarehouse", Stage: "prod"},
+		},
+		{
+			name:   "intern deploys to dev",
+			policy: testPolicy,
+			action: Action{UserID: "U-intern", Verb: "deploy", Repo: "openwarehouse", Stage: "dev"},
+		},
+		{
+			name:    "intern deploys to staging",
+			policy:  testPolicy,
+			action:  Action{UserID: "U-intern", Verb: "deploy", Repo: "openwarehouse", Stage: "staging"},
+			wantErr: true,
+		},
+		{
+			name:   "sre releases from the release channel",
+			policy: testPolicy,
+			action: Action{UserID: "U-sre", ChannelID: "C-release", Verb: "release", Repo: "openwarehouse", Stage: "prod"},
+		},
+		{
+			name:    "sre releases from another channel",
+			policy:  testPolicy,
+			action:  Action{UserID: "U-sre", ChannelID: "C-random", Verb: "release", Repo: "openwarehouse", Stage: "prod"},
+			wantErr: true,
+		},
+		{
+			name:   "user allowed on a repo",
+			policy: testPolicy,
+			action: Action{UserID: "U-nuxt", Verb: "deploy", Repo: "mirror-tv-nuxt", Stage: "staging"},
+		},
+		{
+			name:    "user not allowed on another repo",
+			policy:  testPolicy,
+			action:  Action{UserID: "U-nuxt", Verb: "deploy", Repo: "openwarehouse", Stage: "staging"},
+			wantErr: true,
+		},
+		{
+			name:   "rule for everyone",
+			policy: testPolicy,
+			action: Action{UserID: "U-anyone", Verb: "list"},
+		},
+		{
+			name:    "verb not allowed to anyone",
+			policy:  testPolicy,
+			action:  Action{UserID: "U-sre", Verb: "rollback", Repo: "openwarehouse", Stage: "dev"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Authorize(tt.action); (err != nil) != tt.wantErr {
+				t.Errorf("Policy.Authorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{
+			name:   "valid policy",
+			policy: testPolicy,
+		},
+		{
+			name:    "no verbs",
+			policy:  Policy{Rules: []Rule{{Users: []string{"U-sre"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "undefined group",
+			policy:  Policy{Rules: []Rule{{Groups: []string{"sre"}, Verbs: []string{"deploy"}}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Policy.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package config
+
+// Registry is about how to verify the image tags exist in the container registries before they are deployed
+type Registry struct {
+	// Credentials are the users of the registries requiring authentication
+	Credentials []RegistryCredential `yaml:"credentials"`
+	// PlainHTTP are the hosts of the registries served without TLS, like a local registry
+	PlainHTTP []string `yaml:"plainHTTP"`
+}
+
+// RegistryCredential is a user of a registry. Docker Hub is registry-1.docker.io
+type RegistryCredential struct {
+	Host     string `yaml:"host"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// PasswordFile is read for the password if Password is empty, like the key file of _json_key for gcr.io
+	PasswordFile string `yaml:"passwordFile"`
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultRolloutTimeout = 10 * time.Minute
+
+// Rollout is about waiting for a deployment to roll out on the cluster after it's pushed
+type Rollout struct {
+	// Timeout is a duration like 15m, after which the rollout is reported as failed. DefaultRolloutTimeout is used if it's empty
+	Timeout string `yaml:"timeout"`
+}
+
+// AutoRollback is the policy of a codebase to roll back the deployments which don't roll out
+type AutoRollback struct {
+	Enabled bool `yaml:"enabled"`
+	// Timeout is a duration like 5m for the new pods to become available. The rollout timeout of the bot config is used if it's empty
+	Timeout string `yaml:"timeout"`
+}
+
+// GetTimeout parses Timeout, which must be positive, or returns defaultTimeout if it's empty
+func (a AutoRollback) GetTimeout(defaultTimeout time.Duration) (time.Duration, error) {
+	if a.Timeout == "" {
+		return defaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(a.Timeout)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("auto-rollback timeout(%s) is invalid", a.Timeout))
+	}
+	if timeout <= 0 {
+		return 0, errors.Errorf("auto-rollback timeout(%s) should be positive", a.Timeout)
+	}
+	return timeout, nil
+}
+
+// GetTimeout parses Timeout, which must be positive
+func (r Rollout) GetTimeout() (time.Duration, error) {
+	if r.Timeout == "" {
+		return DefaultRolloutTimeout, nil
+	}
+	timeout, err := time.ParseDuration(r.Timeout)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("rollout timeout(%s) is invalid", r.Timeout))
+	}
+	if timeout <= 0 {
+		return 0, errors.Errorf("rollout timeout(%s) should be positive", r.Timeout)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoRollback_GetTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "rollout timeout",
+			want: DefaultRolloutTimeout,
+		},
+		{
+			name:    "duration",
+			timeout: "3m",
+			want:    3 * time.Minute,
+		},
+		{
+			name:    "not a duration",
+			timeout: "three minutes",
+			wantErr: true,
+		},
+		{
+			name:    "zero",
+			timeout: "0s",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AutoRollback{Enabled: true, Timeout: tt.timeout}.GetTimeout(DefaultRolloutTimeout)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AutoRollback.GetTimeout() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("AutoRollback.GetTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
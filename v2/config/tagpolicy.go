@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TagPolicy restricts the image tags deployed to a stage. A tag has to match Pattern if it's set, and start with one of Prefixes if they are set
+type TagPolicy struct {
+	// Pattern is a regular expression like ^prod_[0-9a-f]{7}$. It's not anchored unless it says so
+	Pattern  string   `yaml:"pattern"`
+	Prefixes []string `yaml:"prefixes"`
+}
+
+// Check returns an error naming the expected pattern and prefixes if the tag is not allowed
+func (p TagPolicy) Check(tag string) error {
+	allowed := true
+	if p.Pattern != "" {
+		matched, err := regexp.MatchString(p.Pattern, tag)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("tag pattern(%s) is invalid", p.Pattern))
+		}
+		allowed = matched
+	}
+	if len(p.Prefixes) != 0 && allowed {
+		allowed = false
+		for _, prefix := range p.Prefixes {
+			if strings.HasPrefix(tag, prefix) {
+				allowed = true
+				break
+			}
+		}
+	}
+	if allowed {
+		return nil
+	}
+
+	var expected []string
+	if p.Pattern != "" {
+		expected = append(expected, fmt.Sprintf("match %s", p.Pattern))
+	}
+	if len(p.Prefixes) != 0 {
+		expected = append(expected, fmt.Sprintf("start with one of %s", strings.Join(p.Prefixes, ", ")))
+	}
+	return errors.Errorf("image-tag(%s) should %s", tag, strings.Join(expected, " and "))
+}
+
+// GetTagPolicy returns the tag policy of the codebase in the stage, or the one shared by all codebases in the stage. ok is false if there is neither
+func (r KubernetesConfigsRepo) GetTagPolicy(codebase Codebase, stage string) (policy TagPolicy, ok bool) {
+	if policy, ok = codebase.TagPolicies[stage]; ok {
+		return policy, ok
+	}
+	policy, ok = r.TagPolicies[stage]
+	return policy, ok
+}
+
+// CheckTag checks the tag against the tag policy of the codebase in the stage. Every tag is allowed if there is no policy
+func (r KubernetesConfigsRepo) CheckTag(codebase Codebase, stage, tag string) error {
+	policy, ok := r.GetTagPolicy(codebase, stage)
+	if !ok {
+		return nil
+	}
+	return errors.Wrap(policy.Check(tag), fmt.Sprintf("stage(%s) of %s doesn't allow it", stage, codebase.Repo))
+}
+
+// ValidateTagPolicies checks the patterns of the tag policies are valid regular expressions
+func (r KubernetesConfigsRepo) ValidateTagPolicies() error {
+	check := func(owner string, policies map[string]TagPolicy) error {
+		for stage, p := range policies {
+			if p.Pattern == "" {
+				continue
+			}
+			if _, err := regexp.Compile(p.Pattern); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("tag pattern(%s) of stage(%s) of %s is invalid", p.Pattern, stage, owner))
+			}
+		}
+		return nil
+	}
+	err := check("all codebases", r.TagPolicies)
+	if err != nil {
+		return err
+	}
+	for _, c := range r.Configs {
+		err = check(c.Repo, c.TagPolicies)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
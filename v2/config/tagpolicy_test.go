@@ -0,0 +1,100 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestKubernetesConfigsRepo_CheckTag(t *testing.T) {
+	repo := KubernetesConfigsRepo{
+		Configs: []Codebase{
+			{
+				Repo: "openwarehouse",
+				TagPolicies: map[string]TagPolicy{
+					"prod": {Pattern: "^prod_[0-9a-f]{7}$"},
+				},
+			},
+			{
+				Repo: "mirror-tv-nuxt",
+			},
+		},
+		TagPolicies: map[string]TagPolicy{
+			"prod":    {Prefixes: []string{"prod_", "v"}},
+			"staging": {Pattern: "^[0-9a-f]{7}$", Prefixes: []string{"staging_", "prod_"}},
+		},
+	}
+	tests := []struct {
+		name     string
+		codebase Codebase
+		stage    string
+		tag      string
+		wantErr  bool
+	}{
+		{
+			name:     "pattern of the codebase",
+			codebase: repo.Configs[0],
+			stage:    "prod",
+			tag:      "prod_399440e",
+		},
+		{
+			name:     "dev tag to prod",
+			codebase: repo.Configs[0],
+			stage:    "prod",
+			tag:      "dev_1bac23",
+			wantErr:  true,
+		},
+		{
+			name:     "policy of the codebase replaces the shared one",
+			codebase: repo.Configs[0],
+			stage:    "prod",
+			tag:      "v1.2.0",
+			wantErr:  true,
+		},
+		{
+			name:     "shared prefixes",
+			codebase: repo.Configs[1],
+			stage:    "prod",
+			tag:      "v1.2.0",
+		},
+		{
+			name:     "not any of the shared prefixes",
+			codebase: repo.Configs[1],
+			stage:    "prod",
+			tag:      "dev_1bac23",
+			wantErr:  true,
+		},
+		{
+			name:     "both pattern and prefixes are required",
+			codebase: repo.Configs[1],
+			stage:    "staging",
+			tag:      "staging_1bac23",
+			wantErr:  true,
+		},
+		{
+			name:     "stage without policy",
+			codebase: repo.Configs[1],
+			stage:    "dev",
+			tag:      "anything",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.CheckTag(tt.codebase, tt.stage, tt.tag); (err != nil) != tt.wantErr {
+				t.Errorf("KubernetesConfigsRepo.CheckTag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKubernetesConfigsRepo_ValidateTagPolicies(t *testing.T) {
+	repo := KubernetesConfigsRepo{
+		Configs: []Codebase{
+			{
+				Repo:        "openwarehouse",
+				TagPolicies: map[string]TagPolicy{"prod": {Pattern: "^prod_[0-9a-f{7}$"}},
+			},
+		},
+	}
+	if err := repo.ValidateTagPolicies(); err == nil {
+		t.Errorf("KubernetesConfigsRepo.ValidateTagPolicies() should fail for an invalid pattern")
+	}
+}
@@ -0,0 +1,214 @@
+// Package freeze blocks the changes during the freeze windows in the config and the freezes by commands
+package freeze
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/pkg/errors"
+)
+
+// Freeze blocks the changes of a project in a stage until it's unfrozen. An empty project or stage matches all
+type Freeze struct {
+	Project string    `json:"project"`
+	Stage   string    `json:"stage"`
+	Reason  string    `json:"reason"`
+	By      string    `json:"by"`
+	Since   time.Time `json:"since"`
+}
+
+func (f Freeze) String() string {
+	return scope(f.Project, f.Stage)
+}
+
+type window struct {
+	config.FreezeWindow
+	from, to time.Time
+	schedule *schedule
+	location *time.Location
+}
+
+func (w window) isActive(now time.Time) bool {
+	if w.schedule != nil {
+		return w.schedule.matches(now.In(w.location))
+	}
+	return !now.Before(w.from) && now.Before(w.to)
+}
+
+// Store checks the changes against the windows and the freezes
+type Store struct {
+	locker    sync.Mutex
+	windows   []window
+	freezes   []Freeze
+	stateFile string
+	now       func() time.Time
+}
+
+// NewStore parses the windows and loads the freezes in the state file
+func NewStore(cfg config.Freeze) (*Store, error) {
+	s := &Store{
+		stateFile: cfg.StateFile,
+		now:       time.Now,
+	}
+	for i, w := range cfg.Windows {
+		parsed, err := parseWindow(w)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("freeze windows[%d] is invalid", i))
+		}
+		s.windows = append(s.windows, parsed)
+	}
+
+	if s.stateFile == "" {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile(s.stateFile)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("reading freeze state file(%s) has error", s.stateFile))
+	}
+	err = json.Unmarshal(b, &s.freezes)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("parsing freeze state file(%s) has error", s.stateFile))
+	}
+	return s, nil
+}
+
+func parseWindow(w config.FreezeWindow) (parsed window, err error) {
+	parsed.FreezeWindow = w
+	if w.Reason == "" {
+		return parsed, errors.New("reason is required")
+	}
+	if w.Schedule != "" {
+		if w.From != "" || w.To != "" {
+			return parsed, errors.New("schedule can't be used with from and to")
+		}
+		parsed.schedule, err = parseSchedule(w.Schedule)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.location = time.Local
+		if w.TimeZone != "" {
+			parsed.location, err = time.LoadLocation(w.TimeZone)
+		}
+		return parsed, err
+	}
+
+	parsed.from, err = time.Parse(time.RFC3339, w.From)
+	if err != nil {
+		return parsed, errors.Wrap(err, "either schedule or from and to is required")
+	}
+	parsed.to, err = time.Parse(time.RFC3339, w.To)
+	if err != nil {
+		return parsed, errors.Wrap(err, "either schedule or from and to is required")
+	}
+	if !parsed.from.Before(parsed.to) {
+		return parsed, errors.Errorf("from(%s) should be before to(%s)", w.From, w.To)
+	}
+	return parsed, nil
+}
+
+// Check returns the reason why the stage of the projects is frozen. Empty projects are changes shared by all projects
+func (s *Store) Check(projects []string, stage string) error {
+	if s == nil {
+		return nil
+	}
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	for _, f := range s.freezes {
+		if isCovered(f.Project, f.Stage, projects, stage) {
+			return errors.Errorf("%s is frozen by %s since %s: %s", f, f.By, f.Since.Format("2006-01-02 15:04"), f.Reason)
+		}
+	}
+	now := s.now()
+	for _, w := range s.windows {
+		if isCovered(w.Project, w.Stage, projects, stage) && w.isActive(now) {
+			return errors.Errorf("%s is in a freeze window: %s", scope(w.Project, w.Stage), w.Reason)
+		}
+	}
+	return nil
+}
+
+// Freeze adds the freeze, or replaces the one of the same project and stage
+func (s *Store) Freeze(f Freeze) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	f.Since = s.now()
+	freezes := []Freeze{f}
+	for _, existing := range s.freezes {
+		if existing.Project != f.Project || existing.Stage != f.Stage {
+			freezes = append(freezes, existing)
+		}
+	}
+	return s.save(freezes)
+}
+
+// Unfreeze removes the freeze of the project and the stage
+func (s *Store) Unfreeze(project, stage string) (Freeze, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	var removed *Freeze
+	var freezes []Freeze
+	for i, f := range s.freezes {
+		if f.Project == project && f.Stage == stage {
+			removed = &s.freezes[i]
+			continue
+		}
+		freezes = append(freezes, f)
+	}
+	if removed == nil {
+		return Freeze{}, errors.Errorf("%s is not frozen", scope(project, stage))
+	}
+	return *removed, s.save(freezes)
+}
+
+// save persists the freezes to the state file before they take effect. The caller must hold the lock
+func (s *Store) save(freezes []Freeze) error {
+	if s.stateFile != "" {
+		b, err := json.MarshalIndent(freezes, "", "  ")
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(s.stateFile, b, 0644)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("writing freeze state file(%s) has error", s.stateFile))
+		}
+	}
+	s.freezes = freezes
+	return nil
+}
+
+// isCovered reports whether the freeze of the project and the stage covers the change of the projects in the stage
+func isCovered(project, stage string, projects []string, changedStage string) bool {
+	if stage != "" && stage != changedStage {
+		return false
+	}
+	if project == "" || len(projects) == 0 {
+		return true
+	}
+	for _, p := range projects {
+		if p == project {
+			return true
+		}
+	}
+	return false
+}
+
+func scope(project, stage string) string {
+	if project == "" {
+		project = "all projects"
+	} else {
+		project = "project(" + project + ")"
+	}
+	if stage == "" {
+		stage = "all stages"
+	} else {
+		stage = "stage(" + stage + ")"
+	}
+	return fmt.Sprintf("%s in %s", stage, project)
+}
@@ -0,0 +1,239 @@
+package freeze
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+)
+
+func Test_parseSchedule(t *testing.T) {
+	taipei := time.FixedZone("Asia/Taipei", 8*60*60)
+	tests := []struct {
+		name    string
+		expr    string
+		time    time.Time
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "weekend on saturday",
+			expr: "* * * * 0,6",
+			time: time.Date(2021, 8, 7, 10, 0, 0, 0, taipei),
+			want: true,
+		},
+		{
+			name: "weekend on monday",
+			expr: "* * * * 0,6",
+			time: time.Date(2021, 8, 9, 10, 0, 0, 0, taipei),
+		},
+		{
+			name: "friday evening",
+			expr: "* 18-23 * * 5",
+			time: time.Date(2021, 8, 6, 18, 30, 0, 0, taipei),
+			want: true,
+		},
+		{
+			name: "friday afternoon",
+			expr: "* 18-23 * * 5",
+			time: time.Date(2021, 8, 6, 17, 59, 0, 0, taipei),
+		},
+		{
+			name: "step",
+			expr: "*/15 * * * *",
+			time: time.Date(2021, 8, 6, 17, 45, 0, 0, taipei),
+			want: true,
+		},
+		{
+			name: "day of month and month",
+			expr: "* * 27 11 *",
+			time: time.Date(2021, 11, 27, 23, 0, 0, 0, taipei),
+			want: true,
+		},
+		{
+			name:    "too few fields",
+			expr:    "* * *",
+			wantErr: true,
+		},
+		{
+			name:    "out of range",
+			expr:    "* 24 * * *",
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			expr:    "* * * * sat",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseSchedule(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := s.matches(tt.time); got != tt.want {
+				t.Errorf("schedule.matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_Check(t *testing.T) {
+	s, err := NewStore(config.Freeze{
+		Windows: []config.FreezeWindow{
+			{
+				Project: "tv",
+				Stage:   "prod",
+				Reason:  "election night",
+				From:    "2021-11-24T18:00:00+08:00",
+				To:      "2021-11-25T06:00:00+08:00",
+			},
+			{
+				Stage:    "prod",
+				Reason:   "weekend",
+				Schedule: "* * * * 0,6",
+				TimeZone: "UTC",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Freeze(Freeze{Project: "readr", Stage: "staging", Reason: "demo", By: "+alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		projects []string
+		stage    string
+		wantErr  bool
+	}{
+		{
+			name:     "in the date range",
+			now:      time.Date(2021, 11, 24, 11, 0, 0, 0, time.UTC),
+			projects: []string{"tv"},
+			stage:    "prod",
+			wantErr:  true,
+		},
+		{
+			name:     "another project in the date range",
+			now:      time.Date(2021, 11, 24, 11, 0, 0, 0, time.UTC),
+			projects: []string{"readr"},
+			stage:    "prod",
+		},
+		{
+			name:    "shared by all projects in the date range",
+			now:     time.Date(2021, 11, 24, 11, 0, 0, 0, time.UTC),
+			stage:   "prod",
+			wantErr: true,
+		},
+		{
+			name:     "after the date range",
+			now:      time.Date(2021, 11, 24, 22, 0, 0, 0, time.UTC),
+			projects: []string{"tv"},
+			stage:    "prod",
+		},
+		{
+			name:     "weekend",
+			now:      time.Date(2021, 8, 7, 10, 0, 0, 0, time.UTC),
+			projects: []string{"readr"},
+			stage:    "prod",
+			wantErr:  true,
+		},
+		{
+			name:     "weekend in another stage",
+			now:      time.Date(2021, 8, 7, 10, 0, 0, 0, time.UTC),
+			projects: []string{"readr"},
+			stage:    "dev",
+		},
+		{
+			name:     "frozen by the command",
+			now:      time.Date(2021, 8, 9, 10, 0, 0, 0, time.UTC),
+			projects: []string{"readr", "tv"},
+			stage:    "staging",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.now = func() time.Time { return tt.now }
+			if err := s.Check(tt.projects, tt.stage); (err != nil) != tt.wantErr {
+				t.Errorf("Store.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStore_Unfreeze(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "freeze.json")
+	s, err := NewStore(config.Freeze{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Freeze(Freeze{Project: "tv", Stage: "prod", Reason: "election night", By: "+alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the freeze survives a restart
+	s, err = NewStore(config.Freeze{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Check([]string{"tv"}, "prod"); err == nil {
+		t.Errorf("Store.Check() should fail after the restart")
+	}
+
+	if _, err := s.Unfreeze("tv", "staging"); err == nil {
+		t.Errorf("Store.Unfreeze() should fail for a stage not frozen")
+	}
+	f, err := s.Unfreeze("tv", "prod")
+	if err != nil {
+		t.Fatalf("Store.Unfreeze() error = %v", err)
+	}
+	if f.Reason != "election night" {
+		t.Errorf("Store.Unfreeze() reason = %v, want %v", f.Reason, "election night")
+	}
+	if err := s.Check([]string{"tv"}, "prod"); err != nil {
+		t.Errorf("Store.Check() error = %v", err)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	tests := []struct {
+		name   string
+		window config.FreezeWindow
+	}{
+		{
+			name:   "no reason",
+			window: config.FreezeWindow{Schedule: "* * * * 0"},
+		},
+		{
+			name:   "neither schedule nor range",
+			window: config.FreezeWindow{Reason: "unknown"},
+		},
+		{
+			name:   "both schedule and range",
+			window: config.FreezeWindow{Reason: "both", Schedule: "* * * * 0", From: "2021-11-27T18:00:00+08:00", To: "2021-11-28T06:00:00+08:00"},
+		},
+		{
+			name:   "reversed range",
+			window: config.FreezeWindow{Reason: "reversed", From: "2021-11-28T06:00:00+08:00", To: "2021-11-27T18:00:00+08:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewStore(config.Freeze{Windows: []config.FreezeWindow{tt.window}}); err == nil {
+				t.Errorf("NewStore() should fail")
+			}
+		})
+	}
+}
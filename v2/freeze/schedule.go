@@ -0,0 +1,86 @@
+package freeze
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// schedule matches the minutes of a cron expression
+type schedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+}
+
+var scheduleFields = []struct {
+	name     string
+	min, max int
+}{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 6},
+}
+
+// parseSchedule parses the five fields of a cron expression. Each field is *, or a list of values and ranges with optional steps like 1-5 or */15. Unlike cron, a minute has to match both day of month and day of week
+func parseSchedule(expr string) (*schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(scheduleFields) {
+		return nil, errors.Errorf("schedule(%s) should have %d fields", expr, len(scheduleFields))
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseScheduleField(field, scheduleFields[i].min, scheduleFields[i].max)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid "+scheduleFields[i].name+" in schedule("+expr+")")
+		}
+		sets[i] = set
+	}
+	return &schedule{minutes: sets[0], hours: sets[1], days: sets[2], months: sets[3], weekdays: sets[4]}, nil
+}
+
+func parseScheduleField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, errors.Errorf("step(%s) should be a positive number", part[i+1:])
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.Errorf("%s is not a number", bounds[0])
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.Errorf("%s is not a number", bounds[1])
+				}
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, errors.Errorf("%s is out of range %d-%d", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the minute of t is in the schedule
+func (s *schedule) matches(t time.Time) bool {
+	return s.minutes[t.Minute()] && s.hours[t.Hour()] && s.days[t.Day()] && s.months[int(t.Month())] && s.weekdays[int(t.Weekday())]
+}
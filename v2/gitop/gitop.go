@@ -3,10 +3,12 @@ package gitop
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
@@ -33,6 +35,16 @@ var k8s = &Repository{
 	locker: &sync.Mutex{},
 }
 
+// NewRepository wraps a git repository opened already, like one in memory
+func NewRepository(name string, r *git.Repository) *Repository {
+	return &Repository{
+		name:   name,
+		once:   &sync.Once{},
+		r:      r,
+		locker: &sync.Mutex{},
+	}
+}
+
 // GetFile will return an billy.Filewith read and write permission
 func (repo *Repository) GetFile(filenamePath string) (billy.File, error) {
 	repo.locker.Lock()
@@ -49,6 +61,17 @@ func (repo *Repository) GetFile(filenamePath string) (billy.File, error) {
 	return f, err
 }
 
+// ReadFile returns the content of the file in the worktree
+func (repo *Repository) ReadFile(filenamePath string) ([]byte, error) {
+	repo.locker.Lock()
+	defer repo.locker.Unlock()
+	worktree, err := repo.r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	return util.ReadFile(worktree.Filesystem, filenamePath)
+}
+
 // AddFile add the file to the staging area of worktree
 func (repo *Repository) AddFile(filenamePath string) error {
 	repo.locker.Lock()
@@ -97,10 +120,13 @@ func (repo *Repository) HardResetToCommit(commit plumbing.Hash) error {
 	return err
 }
 
-// Commit with username as slack caller name annotated by (Major Tom)
-func (repo *Repository) Commit(filename, caller, message string) error {
+// Commit with username as slack caller name annotated by (Major Tom). The caller and the approver are recorded as trailers if the change is approved by someone else
+func (repo *Repository) Commit(filename, caller, approver, message string) error {
 	repo.locker.Lock()
 	defer repo.locker.Unlock()
+	if approver != "" {
+		message = fmt.Sprintf("%s\n\nRequested-by: %s\nApproved-by: %s", strings.TrimRight(message, "\n"), caller, approver)
+	}
 	// TODO extract email and bot name as configuration
 	return commit(repo, filename, fmt.Sprintf("%s(%s)", "Major Tom", caller), "mnews@mnews.tw", message)
 }
@@ -146,7 +172,7 @@ func (repo *Repository) Pull() error {
 		SingleBranch:  true,
 	})
 
-	if err.Error() == "already up-to-date" {
+	if err == git.NoErrAlreadyUpToDate {
 		logrus.Infof("pulling repo, but it's already up-to-date")
 		err = nil
 	} else if err != nil {
@@ -0,0 +1,54 @@
+package gitop
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// Log returns at most n latest commits, which changed any of the paths, from HEAD
+func (repo *Repository) Log(n int, paths ...string) (commits []*object.Commit, err error) {
+	repo.locker.Lock()
+	defer repo.locker.Unlock()
+
+	filter := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		filter[path] = true
+	}
+
+	iter, err := repo.r.Log(&git.LogOptions{
+		Order:      git.LogOrderCommitterTime,
+		PathFilter: func(path string) bool { return filter[path] },
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	err = iter.ForEach(func(c *object.Commit) error {
+		if len(commits) >= n {
+			return storer.ErrStop
+		}
+		commits = append(commits, c)
+		return nil
+	})
+	return commits, err
+}
+
+// ReadFileAtCommit returns the content of the file in the commit
+func (repo *Repository) ReadFileAtCommit(commit plumbing.Hash, filenamePath string) ([]byte, error) {
+	repo.locker.Lock()
+	defer repo.locker.Unlock()
+
+	c, err := repo.r.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.File(filenamePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := f.Contents()
+	return []byte(content), err
+}
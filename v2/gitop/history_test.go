@@ -0,0 +1,183 @@
+package gitop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// testCommit is the files to be written and committed with the message
+type testCommit struct {
+	message string
+	files   map[string]string
+}
+
+// newTestRepository creates an in-memory repository and commits the changes one after another
+func newTestRepository(t *testing.T, commits []testCommit) *Repository {
+	t.Helper()
+	r, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range commits {
+		for path, content := range c.files {
+			if err := util.WriteFile(worktree.Filesystem, path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := worktree.Add(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := worktree.Commit(c.message, &git.CommitOptions{
+			Author: &object.Signature{
+				Name: "tester",
+				When: time.Unix(int64(i), 0),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewRepository("test repo", r)
+}
+
+func TestRepository_Log(t *testing.T) {
+	repo := newTestRepository(t, []testCommit{
+		{message: "c0", files: map[string]string{"a/kustomization.yaml": "0"}},
+		{message: "c1", files: map[string]string{"b/kustomization.yaml": "1"}},
+		{message: "c2", files: map[string]string{"a/kustomization.yaml": "2", "a/hpa.yaml": "2"}},
+		{message: "c3", files: map[string]string{"a/hpa.yaml": "3"}},
+		{message: "c4", files: map[string]string{"b/kustomization.yaml": "4"}},
+	})
+	tests := []struct {
+		name         string
+		n            int
+		paths        []string
+		wantMessages []string
+	}{
+		{
+			name:         "single path",
+			n:            11,
+			paths:        []string{"a/kustomization.yaml"},
+			wantMessages: []string{"c2", "c0"},
+		},
+		{
+			name:         "multiple paths are latest first",
+			n:            11,
+			paths:        []string{"a/kustomization.yaml", "a/hpa.yaml"},
+			wantMessages: []string{"c3", "c2", "c0"},
+		},
+		{
+			name:         "n limits the result",
+			n:            2,
+			paths:        []string{"a/kustomization.yaml", "a/hpa.yaml", "b/kustomization.yaml"},
+			wantMessages: []string{"c4", "c3"},
+		},
+		{
+			name:  "no matching path",
+			n:     11,
+			paths: []string{"c/kustomization.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commits, err := repo.Log(tt.n, tt.paths...)
+			if err != nil {
+				t.Fatalf("Repository.Log() error = %v", err)
+			}
+			var gotMessages []string
+			for _, c := range commits {
+				gotMessages = append(gotMessages, c.Message)
+			}
+			if len(gotMessages) != len(tt.wantMessages) {
+				t.Fatalf("Repository.Log() = %v, want %v", gotMessages, tt.wantMessages)
+			}
+			for i := range gotMessages {
+				if gotMessages[i] != tt.wantMessages[i] {
+					t.Errorf("Repository.Log() = %v, want %v", gotMessages, tt.wantMessages)
+				}
+			}
+		})
+	}
+}
+
+func TestRepository_ReadFileAtCommit(t *testing.T) {
+	repo := newTestRepository(t, []testCommit{
+		{message: "c0", files: map[string]string{"a/kustomization.yaml": "0"}},
+		{message: "c1", files: map[string]string{"a/kustomization.yaml": "1"}},
+	})
+	commits, err := repo.Log(2, "a/kustomization.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"1", "0"} {
+		got, err := repo.ReadFileAtCommit(commits[i].Hash, "a/kustomization.yaml")
+		if err != nil {
+			t.Fatalf("Repository.ReadFileAtCommit() error = %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("Repository.ReadFileAtCommit() = %v, want %v", string(got), want)
+		}
+	}
+	if _, err := repo.ReadFileAtCommit(commits[0].Hash, "b/kustomization.yaml"); err == nil {
+		t.Errorf("Repository.ReadFileAtCommit() should fail for a missing file")
+	}
+}
+
+func TestRepository_Commit(t *testing.T) {
+	tests := []struct {
+		name        string
+		approver    string
+		wantMessage string
+	}{
+		{
+			name:        "by the caller",
+			wantMessage: "deploy(a/prod): deployed by +alice\n",
+		},
+		{
+			name:        "approved by someone else",
+			approver:    "+bob",
+			wantMessage: "deploy(a/prod): deployed by +alice\n\nRequested-by: +alice\nApproved-by: +bob",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepository(t, []testCommit{
+				{message: "c0", files: map[string]string{"a/kustomization.yaml": "0"}},
+			})
+			worktree, err := repo.r.Worktree()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := util.WriteFile(worktree.Filesystem, "a/kustomization.yaml", []byte("1"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.AddFile("a/kustomization.yaml"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := repo.Commit("a/kustomization.yaml", "+alice", tt.approver, "deploy(a/prod): deployed by +alice\n"); err != nil {
+				t.Fatalf("Repository.Commit() error = %v", err)
+			}
+			commits, err := repo.Log(1, "a/kustomization.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if commits[0].Message != tt.wantMessage {
+				t.Errorf("Repository.Commit() message = %q, want %q", commits[0].Message, tt.wantMessage)
+			}
+			if want := "Major Tom(+alice)"; commits[0].Author.Name != want {
+				t.Errorf("Repository.Commit() author = %v, want %v", commits[0].Author.Name, want)
+			}
+		})
+	}
+}
@@ -8,8 +8,12 @@ require (
 	github.com/go-git/go-git/v5 v5.4.2
 	github.com/gookit/config/v2 v2.0.24
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.11.1
+	github.com/sergi/go-diff v1.1.0
 	github.com/sirupsen/logrus v1.8.1
 	github.com/slack-go/slack v0.9.2
+	gopkg.in/yaml.v3 v3.0.1
+	k8s.io/api v0.21.3
 	k8s.io/apimachinery v0.21.3
 	k8s.io/client-go v0.21.3
 )
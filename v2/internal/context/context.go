@@ -4,4 +4,10 @@ type contextValueKey string
 
 const (
 	ResponseChannel contextValueKey = "resp"
+	// Approver is who approved the operation of someone else
+	Approver contextValueKey = "approver"
+	// AuditTrace is the *audit.Trace collecting what the command did
+	AuditTrace contextValueKey = "auditTrace"
+	// FollowUp is the command.FollowUp posting the result of a command after its response
+	FollowUp contextValueKey = "followUp"
 )
@@ -0,0 +1,182 @@
+package k8sop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// ApplicationGVR is the resource of the Argo CD Applications syncing the paths of kubernetes-configs
+var ApplicationGVR = schema.GroupVersionResource{
+	Group:    "argoproj.io",
+	Version:  "v1alpha1",
+	Resource: "applications",
+}
+
+const (
+	// requestedAtInfo is the name of the operation info carrying the requestedAt of a sync request
+	requestedAtInfo = "requestedAt"
+	// argoCDInitiator is the username of the sync operations requested by Major Tom
+	argoCDInitiator = "major-tom"
+)
+
+// ArgoCD is the GitOps engine of Argo CD, which syncs kubernetes-configs by the Applications in Namespace
+type ArgoCD struct {
+	Client    dynamic.Interface
+	Namespace string
+}
+
+// Name implements GitOps
+func (a ArgoCD) Name() string {
+	return "Argo CD"
+}
+
+// GetSyncStatus implements GitOps. The Application closest to the file is the one syncing it. Its health is the Ready condition, unless its last sync operation failed
+func (a ArgoCD) GetSyncStatus(ctx context.Context, filePath string) (SyncStatus, error) {
+	application, err := a.findApplication(ctx, filePath)
+	if err != nil {
+		return SyncStatus{}, err
+	}
+
+	status := SyncStatus{
+		Resource: fmt.Sprintf("Application %s/%s", application.GetNamespace(), application.GetName()),
+	}
+	syncStatus, _, _ := unstructured.NestedString(application.Object, "status", "sync", "status")
+	status.Drifted = syncStatus == "OutOfSync"
+	if syncStatus == "Synced" {
+		status.AppliedRevision, _, _ = unstructured.NestedString(application.Object, "status", "sync", "revision")
+	} else {
+		// history only has the successful syncs
+		history, _, _ := unstructured.NestedSlice(application.Object, "status", "history")
+		if len(history) != 0 {
+			if last, ok := history[len(history)-1].(map[string]interface{}); ok {
+				status.AppliedRevision, _ = last["revision"].(string)
+			}
+		}
+	}
+
+	health, _, _ := unstructured.NestedString(application.Object, "status", "health", "status")
+	healthMessage, _, _ := unstructured.NestedString(application.Object, "status", "health", "message")
+	switch health {
+	case "Healthy":
+		status.Ready = Condition{Status: "True", Reason: health, Message: healthMessage}
+	case "Degraded", "Missing":
+		status.Ready = Condition{Status: "False", Reason: health, Message: healthMessage}
+	case "":
+		status.Ready = Condition{Status: "Unknown"}
+	default:
+		status.Ready = Condition{Status: "Unknown", Reason: health, Message: healthMessage}
+	}
+
+	// a running operation has yet to tell whether its revision fails
+	phase, _, _ := unstructured.NestedString(application.Object, "status", "operationState", "phase")
+	switch phase {
+	case "Succeeded", "Failed", "Error":
+		status.AttemptedRevision, _, _ = unstructured.NestedString(application.Object, "status", "operationState", "syncResult", "revision")
+		status.LastHandledRequest = operationInfo(application, requestedAtInfo)
+	}
+	if phase == "Failed" || phase == "Error" {
+		message, _, _ := unstructured.NestedString(application.Object, "status", "operationState", "message")
+		status.Ready = Condition{Status: "False", Reason: "Sync" + phase, Message: message}
+	}
+	return status, nil
+}
+
+// RequestSync implements GitOps. A sync operation to the target revision is set on the Application syncing the file as argocd app sync does, which is refused while another operation is in progress
+func (a ArgoCD) RequestSync(ctx context.Context, filePath, requestedAt string) (string, error) {
+	application, err := a.findApplication(ctx, filePath)
+	if err != nil {
+		return "", err
+	}
+	resource := fmt.Sprintf("Application %s/%s", application.GetNamespace(), application.GetName())
+	if _, isExisting := application.Object["operation"]; isExisting {
+		return "", errors.Errorf("another operation is in progress on %s", resource)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"operation": map[string]interface{}{
+			"initiatedBy": map[string]interface{}{"username": argoCDInitiator},
+			"info":        []interface{}{map[string]interface{}{"name": requestedAtInfo, "value": requestedAt}},
+			"sync":        map[string]interface{}{},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	_, err = a.Client.Resource(ApplicationGVR).Namespace(application.GetNamespace()).Patch(ctx, application.GetName(), types.MergePatchType, patch, v1.PatchOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("requesting sync of Argo CD application(%s/%s) has error", application.GetNamespace(), application.GetName()))
+	}
+	return resource, nil
+}
+
+// findApplication returns the Application closest to the file at filePath, which is the one with the deepest source path containing it, or the overlay including its base. Every source of a multi-source Application is considered
+func (a ArgoCD) findApplication(ctx context.Context, filePath string) (*unstructured.Unstructured, error) {
+	if a.Client == nil {
+		return nil, errors.New("no dynamic client is configured")
+	}
+	list, err := a.Client.Resource(ApplicationGVR).Namespace(a.Namespace).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("listing Argo CD applications in namespace(%s) has error", a.Namespace))
+	}
+
+	dir := path.Dir(filePath)
+	var application *unstructured.Unstructured
+	var closest pathMatch
+	for i, item := range list.Items {
+		for _, sourcePath := range sourcePaths(item) {
+			match, isApplying := matchPath(dir, sourcePath)
+			if !isApplying {
+				continue
+			}
+			if application == nil || match.isCloserThan(closest) {
+				application, closest = &list.Items[i], match
+			}
+		}
+	}
+	if application == nil {
+		return nil, errors.Errorf("no Argo CD application in namespace(%s) syncs %s", a.Namespace, dir)
+	}
+	return application, nil
+}
+
+// sourcePaths returns the paths of the sources of the Application. A source without a path, like a Helm chart, isn't in kubernetes-configs
+func sourcePaths(application unstructured.Unstructured) []string {
+	var paths []string
+	if p, isFound, _ := unstructured.NestedString(application.Object, "spec", "source", "path"); isFound && p != "" {
+		paths = append(paths, p)
+	}
+	sources, _, _ := unstructured.NestedSlice(application.Object, "spec", "sources")
+	for _, s := range sources {
+		source, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if p, _ := source["path"].(string); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// operationInfo returns the value of the info of the last operation on the Application
+func operationInfo(application *unstructured.Unstructured, name string) string {
+	info, _, _ := unstructured.NestedSlice(application.Object, "status", "operationState", "operation", "info")
+	for _, i := range info {
+		pair, ok := i.(map[string]interface{})
+		if !ok || pair["name"] != name {
+			continue
+		}
+		value, _ := pair["value"].(string)
+		return value
+	}
+	return ""
+}
@@ -0,0 +1,202 @@
+package k8sop
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newApplication(name string, spec, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": ApplicationGVR.GroupVersion().String(),
+		"kind":       "Application",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "argocd",
+		},
+		"spec":   spec,
+		"status": status,
+	}}
+}
+
+func newArgoCDClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		ApplicationGVR: "ApplicationList",
+	}, objects...)
+}
+
+func TestArgoCD_GetSyncStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      map[string]interface{}
+		want        SyncStatus
+		wantApplied bool
+		wantFailed  bool
+	}{
+		{
+			name: "synced and healthy",
+			status: map[string]interface{}{
+				"sync":   map[string]interface{}{"status": "Synced", "revision": "1bac23"},
+				"health": map[string]interface{}{"status": "Healthy"},
+				"operationState": map[string]interface{}{
+					"phase":      "Succeeded",
+					"operation":  map[string]interface{}{"info": []interface{}{map[string]interface{}{"name": "requestedAt", "value": "2026-10-18T08:00:00Z"}}},
+					"syncResult": map[string]interface{}{"revision": "1bac23"},
+				},
+			},
+			want: SyncStatus{
+				Resource:           "Application argocd/openwarehouse-dev",
+				Ready:              Condition{Status: "True", Reason: "Healthy"},
+				AppliedRevision:    "1bac23",
+				AttemptedRevision:  "1bac23",
+				LastHandledRequest: "2026-10-18T08:00:00Z",
+			},
+			wantApplied: true,
+		},
+		{
+			name: "sync failed",
+			status: map[string]interface{}{
+				"sync":    map[string]interface{}{"status": "OutOfSync", "revision": "1bac23"},
+				"health":  map[string]interface{}{"status": "Healthy"},
+				"history": []interface{}{map[string]interface{}{"revision": "0abc12"}},
+				"operationState": map[string]interface{}{
+					"phase":      "Failed",
+					"message":    "one or more objects failed to apply",
+					"syncResult": map[string]interface{}{"revision": "1bac23"},
+				},
+			},
+			want: SyncStatus{
+				Resource:          "Application argocd/openwarehouse-dev",
+				Drifted:           true,
+				Ready:             Condition{Status: "False", Reason: "SyncFailed", Message: "one or more objects failed to apply"},
+				AppliedRevision:   "0abc12",
+				AttemptedRevision: "1bac23",
+			},
+			wantFailed: true,
+		},
+		{
+			name: "syncing",
+			status: map[string]interface{}{
+				"sync":    map[string]interface{}{"status": "OutOfSync", "revision": "1bac23"},
+				"health":  map[string]interface{}{"status": "Progressing"},
+				"history": []interface{}{map[string]interface{}{"revision": "0abc12"}},
+				"operationState": map[string]interface{}{
+					"phase":      "Running",
+					"syncResult": map[string]interface{}{"revision": "1bac23"},
+				},
+			},
+			want: SyncStatus{
+				Resource:        "Application argocd/openwarehouse-dev",
+				Drifted:         true,
+				Ready:           Condition{Status: "Unknown", Reason: "Progressing"},
+				AppliedRevision: "0abc12",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := newArgoCDClient(
+				newApplication("cluster", map[string]interface{}{"source": map[string]interface{}{"path": "."}}, nil),
+				newApplication("openwarehouse-dev", map[string]interface{}{
+					"sources": []interface{}{
+						map[string]interface{}{"chart": "redis"},
+						map[string]interface{}{"path": "openwarehouse/overlays/dev"},
+					},
+				}, tt.status),
+			)
+			got, err := ArgoCD{Client: client, Namespace: "argocd"}.GetSyncStatus(context.Background(), "openwarehouse/overlays/dev/kustomization.yaml")
+			if err != nil {
+				t.Fatalf("ArgoCD.GetSyncStatus() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ArgoCD.GetSyncStatus() = %+v, want %+v", got, tt.want)
+			}
+			if got.HasApplied("1bac23") != tt.wantApplied || got.HasFailed("1bac23") != tt.wantFailed {
+				t.Errorf("SyncStatus.HasApplied() = %v, SyncStatus.HasFailed() = %v, want %v, %v", got.HasApplied("1bac23"), got.HasFailed("1bac23"), tt.wantApplied, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestArgoCD_findApplication(t *testing.T) {
+	client := newArgoCDClient(
+		newApplication("cluster", map[string]interface{}{"source": map[string]interface{}{"path": "."}}, nil),
+		newApplication("openwarehouse-dev", map[string]interface{}{"source": map[string]interface{}{"path": "openwarehouse/overlays/dev"}}, nil),
+		newApplication("openwarehouse-prod-tv-cms", map[string]interface{}{"source": map[string]interface{}{"path": "openwarehouse/overlays/prod/overlays/tv/overlays/cms"}}, nil),
+	)
+	tests := []struct {
+		name     string
+		filePath string
+		want     string
+	}{
+		{
+			name:     "type 2 base of the stage",
+			filePath: "openwarehouse/overlays/dev/base/kustomization.yaml",
+			want:     "openwarehouse-dev",
+		},
+		{
+			name:     "type 2 base of the project included by the overlay",
+			filePath: "openwarehouse/overlays/prod/overlays/tv/base/kustomization.yaml",
+			want:     "openwarehouse-prod-tv-cms",
+		},
+		{
+			name:     "type 2 overlay of the service",
+			filePath: "openwarehouse/overlays/prod/overlays/tv/overlays/cms/hpa.yaml",
+			want:     "openwarehouse-prod-tv-cms",
+		},
+		{
+			name:     "type 1 overlay",
+			filePath: "mirror-tv-nuxt/overlays/prod/kustomization.yaml",
+			want:     "cluster",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ArgoCD{Client: client, Namespace: "argocd"}.findApplication(context.Background(), tt.filePath)
+			if err != nil {
+				t.Fatalf("ArgoCD.findApplication() error = %v", err)
+			}
+			if got.GetName() != tt.want {
+				t.Errorf("ArgoCD.findApplication() = %v, want %v", got.GetName(), tt.want)
+			}
+		})
+	}
+}
+
+func TestArgoCD_RequestSync(t *testing.T) {
+	requestedAt := "2026-10-18T08:00:00Z"
+	client := newArgoCDClient(
+		newApplication("openwarehouse-dev", map[string]interface{}{"source": map[string]interface{}{"path": "openwarehouse/overlays/dev"}}, nil),
+	)
+	argoCD := ArgoCD{Client: client, Namespace: "argocd"}
+	got, err := argoCD.RequestSync(context.Background(), "openwarehouse/overlays/dev/kustomization.yaml", requestedAt)
+	if err != nil {
+		t.Fatalf("ArgoCD.RequestSync() error = %v", err)
+	}
+	if got != "Application argocd/openwarehouse-dev" {
+		t.Errorf("ArgoCD.RequestSync() = %v, want %v", got, "Application argocd/openwarehouse-dev")
+	}
+	application, err := client.Resource(ApplicationGVR).Namespace("argocd").Get(context.Background(), "openwarehouse-dev", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _, _ := unstructured.NestedSlice(application.Object, "operation", "info")
+	if len(info) != 1 || info[0].(map[string]interface{})["value"] != requestedAt {
+		t.Errorf("operation info = %v, want %v", info, requestedAt)
+	}
+
+	// the operation is still in progress
+	if _, err := argoCD.RequestSync(context.Background(), "openwarehouse/overlays/dev/kustomization.yaml", requestedAt); err == nil {
+		t.Errorf("ArgoCD.RequestSync() during an operation has no error")
+	}
+	if _, err := argoCD.RequestSync(context.Background(), "mirror-tv-nuxt/overlays/dev/kustomization.yaml", requestedAt); err == nil {
+		t.Errorf("ArgoCD.RequestSync() without an application has no error")
+	}
+}
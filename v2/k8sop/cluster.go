@@ -0,0 +1,103 @@
+package k8sop
+
+import (
+	"sync"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Cluster is the client to the cluster where a service is running
+type Cluster struct {
+	Clientset kubernetes.Interface
+	// Dynamic is the client of the custom resources like the Flux ones
+	Dynamic   dynamic.Interface
+	Namespace string
+	// GitOps is the engine syncing kubernetes-configs to the cluster
+	GitOps GitOps
+}
+
+// ClusterResolver resolves the cluster of a service by the registry in config. Clients are created once per cluster
+type ClusterResolver struct {
+	clusters config.Clusters
+	locker   sync.Mutex
+	cache    map[config.Cluster]*Cluster
+	// newClientset and newDynamic are replaceable in tests
+	newClientset func(restConfig *rest.Config) (kubernetes.Interface, error)
+	newDynamic   func(restConfig *rest.Config) (dynamic.Interface, error)
+}
+
+// NewClusterResolver validates the registry and makes sure every kubeconfig and its context can be loaded
+func NewClusterResolver(clusters config.Clusters) (*ClusterResolver, error) {
+	if err := clusters.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validating clusters has error")
+	}
+	for _, c := range clusters {
+		if _, err := getRestConfig(c); err != nil {
+			return nil, err
+		}
+	}
+	return &ClusterResolver{
+		clusters: clusters,
+		cache:    make(map[config.Cluster]*Cluster),
+		newClientset: func(restConfig *rest.Config) (kubernetes.Interface, error) {
+			return kubernetes.NewForConfig(restConfig)
+		},
+		newDynamic: func(restConfig *rest.Config) (dynamic.Interface, error) {
+			return dynamic.NewForConfig(restConfig)
+		},
+	}, nil
+}
+
+// Resolve returns the cluster of the repo in the project and the stage
+func (r *ClusterResolver) Resolve(repo, project, stage string) (*Cluster, error) {
+	if r == nil {
+		return nil, errors.New("no cluster is configured")
+	}
+	c, err := r.clusters.Get(repo, project, stage)
+	if err != nil {
+		return nil, err
+	}
+
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if cluster, isExisting := r.cache[c]; isExisting {
+		return cluster, nil
+	}
+
+	restConfig, err := getRestConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := r.newClientset(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating clientset for "+c.String()+" has error")
+	}
+	dynamicClient, err := r.newDynamic(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating dynamic client for "+c.String()+" has error")
+	}
+	cluster := &Cluster{
+		Clientset: clientset,
+		Dynamic:   dynamicClient,
+		Namespace: c.Namespace,
+		GitOps:    newGitOps(c, dynamicClient),
+	}
+	r.cache[c] = cluster
+	return cluster, nil
+}
+
+func getRestConfig(c config.Cluster) (*rest.Config, error) {
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: c.KubeConfigPath},
+		&clientcmd.ConfigOverrides{CurrentContext: c.Context},
+	).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading kubeconfig for "+c.String()+" has error")
+	}
+	return restConfig, nil
+}
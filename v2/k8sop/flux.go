@@ -0,0 +1,160 @@
+package k8sop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	// KustomizationGVR is the resource of the Flux v2 Kustomizations applying the paths of kubernetes-configs
+	KustomizationGVR = schema.GroupVersionResource{
+		Group:    "kustomize.toolkit.fluxcd.io",
+		Version:  "v1",
+		Resource: "kustomizations",
+	}
+	// GitRepositoryGVR is the resource of the Flux v2 GitRepositories fetching kubernetes-configs
+	GitRepositoryGVR = schema.GroupVersionResource{
+		Group:    "source.toolkit.fluxcd.io",
+		Version:  "v1",
+		Resource: "gitrepositories",
+	}
+)
+
+// ReconcileAnnotation asks Flux to reconcile a resource right away instead of at its interval
+const ReconcileAnnotation = "reconcile.fluxcd.io/requestedAt"
+
+// Flux is the GitOps engine of Flux v2, which applies kubernetes-configs by the Kustomizations in Namespace
+type Flux struct {
+	Client    dynamic.Interface
+	Namespace string
+}
+
+// Name implements GitOps
+func (f Flux) Name() string {
+	return "Flux"
+}
+
+// GetSyncStatus implements GitOps. The Kustomization closest to the file is the one applying it
+func (f Flux) GetSyncStatus(ctx context.Context, filePath string) (SyncStatus, error) {
+	kustomization, err := f.findKustomization(ctx, filePath)
+	if err != nil {
+		return SyncStatus{}, err
+	}
+
+	status := SyncStatus{
+		Resource: fmt.Sprintf("Kustomization %s/%s", kustomization.GetNamespace(), kustomization.GetName()),
+		Ready:    readyCondition(kustomization),
+	}
+	status.Suspended, _, _ = unstructured.NestedBool(kustomization.Object, "spec", "suspend")
+	status.AppliedRevision, _, _ = unstructured.NestedString(kustomization.Object, "status", "lastAppliedRevision")
+	status.AttemptedRevision, _, _ = unstructured.NestedString(kustomization.Object, "status", "lastAttemptedRevision")
+	status.LastHandledRequest, _, _ = unstructured.NestedString(kustomization.Object, "status", "lastHandledReconcileAt")
+
+	sourceKind, sourceName, sourceNamespace := sourceRef(kustomization)
+	if sourceKind != "GitRepository" {
+		return status, nil
+	}
+	source, err := f.Client.Resource(GitRepositoryGVR).Namespace(sourceNamespace).Get(ctx, sourceName, v1.GetOptions{})
+	if err != nil {
+		return status, errors.Wrap(err, fmt.Sprintf("getting Flux git repository(%s/%s) has error", sourceNamespace, sourceName))
+	}
+	status.Source = fmt.Sprintf("GitRepository %s/%s", sourceNamespace, sourceName)
+	status.SourceReady = readyCondition(source)
+	status.SourceRevision, _, _ = unstructured.NestedString(source.Object, "status", "artifact", "revision")
+	return status, nil
+}
+
+// RequestSync implements GitOps. The GitRepository and the Kustomization applying the file are annotated with requestedAt as flux reconcile does
+func (f Flux) RequestSync(ctx context.Context, filePath, requestedAt string) (string, error) {
+	kustomization, err := f.findKustomization(ctx, filePath)
+	if err != nil {
+		return "", err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{ReconcileAnnotation: requestedAt},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// the source is fetched first so the kustomization can apply the latest revision
+	sourceKind, sourceName, sourceNamespace := sourceRef(kustomization)
+	if sourceKind == "GitRepository" {
+		_, err = f.Client.Resource(GitRepositoryGVR).Namespace(sourceNamespace).Patch(ctx, sourceName, types.MergePatchType, patch, v1.PatchOptions{})
+		if err != nil {
+			return "", errors.Wrap(err, fmt.Sprintf("annotating Flux git repository(%s/%s) has error", sourceNamespace, sourceName))
+		}
+	}
+	_, err = f.Client.Resource(KustomizationGVR).Namespace(kustomization.GetNamespace()).Patch(ctx, kustomization.GetName(), types.MergePatchType, patch, v1.PatchOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("annotating Flux kustomization(%s/%s) has error", kustomization.GetNamespace(), kustomization.GetName()))
+	}
+	return fmt.Sprintf("Kustomization %s/%s", kustomization.GetNamespace(), kustomization.GetName()), nil
+}
+
+// findKustomization returns the Kustomization closest to the file at filePath, which is the one with the deepest path containing it, or the overlay including its base
+func (f Flux) findKustomization(ctx context.Context, filePath string) (*unstructured.Unstructured, error) {
+	if f.Client == nil {
+		return nil, errors.New("no dynamic client is configured")
+	}
+	list, err := f.Client.Resource(KustomizationGVR).Namespace(f.Namespace).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("listing Flux kustomizations in namespace(%s) has error", f.Namespace))
+	}
+
+	dir := path.Dir(filePath)
+	var kustomization *unstructured.Unstructured
+	var closest pathMatch
+	for i, item := range list.Items {
+		specPath, _, _ := unstructured.NestedString(item.Object, "spec", "path")
+		match, isApplying := matchPath(dir, specPath)
+		if !isApplying {
+			continue
+		}
+		if kustomization == nil || match.isCloserThan(closest) {
+			kustomization, closest = &list.Items[i], match
+		}
+	}
+	if kustomization == nil {
+		return nil, errors.Errorf("no Flux kustomization in namespace(%s) applies %s", f.Namespace, dir)
+	}
+	return kustomization, nil
+}
+
+// sourceRef returns the source of the Kustomization, which is in the namespace of the Kustomization by default
+func sourceRef(kustomization *unstructured.Unstructured) (kind, name, namespace string) {
+	kind, _, _ = unstructured.NestedString(kustomization.Object, "spec", "sourceRef", "kind")
+	name, _, _ = unstructured.NestedString(kustomization.Object, "spec", "sourceRef", "name")
+	namespace, _, _ = unstructured.NestedString(kustomization.Object, "spec", "sourceRef", "namespace")
+	if namespace == "" {
+		namespace = kustomization.GetNamespace()
+	}
+	return kind, name, namespace
+}
+
+// readyCondition returns the Ready condition in the status of a Flux resource
+func readyCondition(obj *unstructured.Unstructured) Condition {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		status, _ := condition["status"].(string)
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+		return Condition{Status: status, Reason: reason, Message: message}
+	}
+	return Condition{}
+}
@@ -0,0 +1,179 @@
+package k8sop
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newFluxObject(gvr schema.GroupVersionResource, kind, name string, spec, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": gvr.GroupVersion().String(),
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "flux-system",
+		},
+		"spec":   spec,
+		"status": status,
+	}}
+}
+
+func readyConditions(status, reason, message string) []interface{} {
+	return []interface{}{map[string]interface{}{"type": "Ready", "status": status, "reason": reason, "message": message}}
+}
+
+func newFluxClient() *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		KustomizationGVR: "KustomizationList",
+		GitRepositoryGVR: "GitRepositoryList",
+	},
+		newFluxObject(KustomizationGVR, "Kustomization", "cluster", map[string]interface{}{
+			"path":      "./",
+			"sourceRef": map[string]interface{}{"kind": "GitRepository", "name": "kubernetes-configs"},
+		}, map[string]interface{}{
+			"lastAppliedRevision":   "main@sha1:0abc12",
+			"lastAttemptedRevision": "main@sha1:0abc12",
+			"conditions":            readyConditions("True", "ReconciliationSucceeded", "Applied revision: main@sha1:0abc12"),
+		}),
+		newFluxObject(KustomizationGVR, "Kustomization", "openwarehouse-dev", map[string]interface{}{
+			"path":      "./openwarehouse/overlays/dev",
+			"sourceRef": map[string]interface{}{"kind": "GitRepository", "name": "kubernetes-configs"},
+		}, map[string]interface{}{
+			"lastAppliedRevision":   "main@sha1:0abc12",
+			"lastAttemptedRevision": "main@sha1:1bac23",
+			"conditions":            readyConditions("False", "BuildFailed", "kustomize build failed"),
+		}),
+		newFluxObject(KustomizationGVR, "Kustomization", "openwarehouse-prod-tv-cms", map[string]interface{}{
+			"path":      "./openwarehouse/overlays/prod/overlays/tv/overlays/cms",
+			"sourceRef": map[string]interface{}{"kind": "GitRepository", "name": "kubernetes-configs"},
+		}, map[string]interface{}{
+			"lastAppliedRevision":   "main@sha1:0abc12",
+			"lastAttemptedRevision": "main@sha1:0abc12",
+			"conditions":            readyConditions("True", "ReconciliationSucceeded", "Applied revision: main@sha1:0abc12"),
+		}),
+		newFluxObject(GitRepositoryGVR, "GitRepository", "kubernetes-configs", map[string]interface{}{
+			"url": "ssh://git@github.com/mirror-media/kubernetes-configs",
+		}, map[string]interface{}{
+			"artifact":   map[string]interface{}{"revision": "main@sha1:1bac23"},
+			"conditions": readyConditions("True", "Succeeded", ""),
+		}),
+	)
+}
+
+func TestFlux_GetSyncStatus(t *testing.T) {
+	client := newFluxClient()
+	tests := []struct {
+		name              string
+		namespace         string
+		filePath          string
+		wantKustomization string
+		wantApplied       bool
+		wantFailed        bool
+		wantErr           bool
+	}{
+		{
+			name:              "kustomization of the overlay",
+			namespace:         "flux-system",
+			filePath:          "openwarehouse/overlays/dev/kustomization.yaml",
+			wantKustomization: "Kustomization flux-system/openwarehouse-dev",
+			wantFailed:        true,
+		},
+		{
+			name:              "kustomization of the whole repo",
+			namespace:         "flux-system",
+			filePath:          "mirror-tv-nuxt/overlays/dev/kustomization.yaml",
+			wantKustomization: "Kustomization flux-system/cluster",
+		},
+		{
+			name:              "type 2 base of the stage",
+			namespace:         "flux-system",
+			filePath:          "openwarehouse/overlays/dev/base/kustomization.yaml",
+			wantKustomization: "Kustomization flux-system/openwarehouse-dev",
+			wantFailed:        true,
+		},
+		{
+			name:              "type 2 base of the project included by the overlay",
+			namespace:         "flux-system",
+			filePath:          "openwarehouse/overlays/prod/overlays/tv/base/kustomization.yaml",
+			wantKustomization: "Kustomization flux-system/openwarehouse-prod-tv-cms",
+		},
+		{
+			name:              "type 2 overlay of the service",
+			namespace:         "flux-system",
+			filePath:          "openwarehouse/overlays/prod/overlays/tv/overlays/cms/hpa.yaml",
+			wantKustomization: "Kustomization flux-system/openwarehouse-prod-tv-cms",
+		},
+		{
+			name:              "type 2 base of another project",
+			namespace:         "flux-system",
+			filePath:          "openwarehouse/overlays/prod/overlays/readr/base/kustomization.yaml",
+			wantKustomization: "Kustomization flux-system/cluster",
+		},
+		{
+			name:      "flux in another namespace",
+			namespace: "flux",
+			filePath:  "openwarehouse/overlays/dev/kustomization.yaml",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Flux{Client: client, Namespace: tt.namespace}.GetSyncStatus(context.Background(), tt.filePath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Flux.GetSyncStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Resource != tt.wantKustomization || got.Source != "GitRepository flux-system/kubernetes-configs" || got.SourceRevision != "main@sha1:1bac23" {
+				t.Errorf("Flux.GetSyncStatus() = %+v", got)
+			}
+			if got.HasApplied("1bac23") != tt.wantApplied || got.HasFailed("1bac23") != tt.wantFailed {
+				t.Errorf("SyncStatus.HasApplied() = %v, SyncStatus.HasFailed() = %v, want %v, %v", got.HasApplied("1bac23"), got.HasFailed("1bac23"), tt.wantApplied, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestFlux_RequestSync(t *testing.T) {
+	client := newFluxClient()
+	requestedAt := "2026-10-18T08:00:00Z"
+	flux := Flux{Client: client, Namespace: "flux-system"}
+	got, err := flux.RequestSync(context.Background(), "openwarehouse/overlays/dev/kustomization.yaml", requestedAt)
+	if err != nil {
+		t.Fatalf("Flux.RequestSync() error = %v", err)
+	}
+	if got != "Kustomization flux-system/openwarehouse-dev" {
+		t.Errorf("Flux.RequestSync() = %v, want %v", got, "Kustomization flux-system/openwarehouse-dev")
+	}
+	for gvr, name := range map[schema.GroupVersionResource]string{
+		KustomizationGVR: "openwarehouse-dev",
+		GitRepositoryGVR: "kubernetes-configs",
+	} {
+		obj, err := client.Resource(gvr).Namespace("flux-system").Get(context.Background(), name, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("getting %s has error: %v", name, err)
+		}
+		if annotation := obj.GetAnnotations()[ReconcileAnnotation]; annotation != requestedAt {
+			t.Errorf("annotation of %s = %v, want %v", name, annotation, requestedAt)
+		}
+	}
+	// the kustomization of the whole repo isn't requested
+	obj, err := client.Resource(KustomizationGVR).Namespace("flux-system").Get(context.Background(), "cluster", v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting cluster has error: %v", err)
+	}
+	if _, isExisting := obj.GetAnnotations()[ReconcileAnnotation]; isExisting {
+		t.Errorf("annotations of cluster = %v", obj.GetAnnotations())
+	}
+
+	if _, err := (Flux{Client: client, Namespace: "default"}).RequestSync(context.Background(), "openwarehouse/overlays/dev/kustomization.yaml", requestedAt); err == nil {
+		t.Errorf("Flux.RequestSync() in a namespace without kustomizations has no error")
+	}
+}
@@ -0,0 +1,219 @@
+package k8sop
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/dynamic"
+)
+
+// GitOps is the engine syncing kubernetes-configs to a cluster, like Flux v2 or Argo CD
+type GitOps interface {
+	// Name is how the engine is called in the messages
+	Name() string
+	// GetSyncStatus returns how the engine syncs the file of kubernetes-configs at filePath to the cluster
+	GetSyncStatus(ctx context.Context, filePath string) (SyncStatus, error)
+	// RequestSync asks the engine to sync the file at filePath right away instead of at its interval. requestedAt is the LastHandledRequest of the status once the request is handled. The resource asked is returned
+	RequestSync(ctx context.Context, filePath, requestedAt string) (resource string, err error)
+}
+
+// newGitOps returns the GitOps engine of the cluster in config
+func newGitOps(c config.Cluster, client dynamic.Interface) GitOps {
+	if c.GetGitOps() == config.GitOpsArgoCD {
+		return ArgoCD{Client: client, Namespace: c.GetArgoCDNamespace()}
+	}
+	return Flux{Client: client, Namespace: c.GetFluxNamespace()}
+}
+
+// ErrSyncFailed is returned by WaitForSync if the GitOps engine failed to apply the commit
+var ErrSyncFailed = errors.New("sync failed")
+
+// Condition is the Ready condition of a GitOps resource
+type Condition struct {
+	// Status is True, False or Unknown
+	Status  string
+	Reason  string
+	Message string
+}
+
+func (c Condition) String() string {
+	if c.Status == "" {
+		return "Ready Unknown"
+	}
+	s := "Ready " + c.Status
+	if c.Reason != "" {
+		s += " " + c.Reason
+	}
+	if c.Message != "" {
+		s += ": " + c.Message
+	}
+	return s
+}
+
+// SyncStatus is how a GitOps engine syncs a path of kubernetes-configs to the cluster
+type SyncStatus struct {
+	// Resource is the kind and the namespace/name of the resource syncing the path, like Kustomization flux-system/openwarehouse
+	Resource  string
+	Suspended bool
+	// Drifted is true if the engine found the live resources differ from kubernetes-configs
+	Drifted bool
+	Ready   Condition
+	// AppliedRevision is the last revision applied, like main@sha1:<commit>
+	AppliedRevision string
+	// AttemptedRevision is the last revision tried to apply, which failed if it's not applied
+	AttemptedRevision string
+	// Source is the kind and the namespace/name of the resource fetching kubernetes-configs, like GitRepository flux-system/kubernetes-configs. It's empty if the engine has no such resource
+	Source      string
+	SourceReady Condition
+	// SourceRevision is the last revision fetched by the source
+	SourceRevision string
+	// LastHandledRequest is the requestedAt of the last sync request handled
+	LastHandledRequest string
+}
+
+// HasApplied reports whether the commit is the last revision applied
+func (s SyncStatus) HasApplied(commit string) bool {
+	return commit != "" && revisionCommit(s.AppliedRevision) == commit
+}
+
+// HasFailed reports whether the commit was tried but failed to apply
+func (s SyncStatus) HasFailed(commit string) bool {
+	return commit != "" && revisionCommit(s.AttemptedRevision) == commit && !s.HasApplied(commit) && s.Ready.Status == "False"
+}
+
+func (s SyncStatus) String() string {
+	resource := fmt.Sprintf("%s applied %s, %s", s.Resource, s.AppliedRevision, s.Ready)
+	if s.Suspended {
+		resource += ", suspended"
+	}
+	if s.Drifted {
+		resource += ", out of sync"
+	}
+	if s.Source == "" {
+		return resource
+	}
+	return fmt.Sprintf("%s; %s fetched %s, %s", resource, s.Source, s.SourceRevision, s.SourceReady)
+}
+
+// revisionCommit returns the commit of a revision, which is like main@sha1:<commit>, or main/<commit> before Flux v2.0, or the commit itself for Argo CD
+func revisionCommit(revision string) string {
+	if i := strings.LastIndexAny(revision, "/:"); i >= 0 {
+		return revision[i+1:]
+	}
+	return revision
+}
+
+// GitOpsName returns the name of the GitOps engine of the cluster
+func (c *Cluster) GitOpsName() string {
+	if c.GitOps == nil {
+		return "GitOps"
+	}
+	return c.GitOps.Name()
+}
+
+// GetSyncStatus returns how the GitOps engine syncs the file of kubernetes-configs at filePath to the cluster
+func (c *Cluster) GetSyncStatus(ctx context.Context, filePath string) (SyncStatus, error) {
+	if c.GitOps == nil {
+		return SyncStatus{}, errors.New("no GitOps engine is configured")
+	}
+	return c.GitOps.GetSyncStatus(ctx, filePath)
+}
+
+// WaitForSync polls the sync status every interval until the GitOps engine has applied the commit, failed to apply it, or ctx is done. An error getting the status is returned right away as the engine may not be installed
+func (c *Cluster) WaitForSync(ctx context.Context, filePath, commit string, interval time.Duration) (SyncStatus, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := c.GetSyncStatus(ctx, filePath)
+		switch {
+		case err != nil:
+			return status, err
+		case status.HasApplied(commit):
+			return status, nil
+		case status.HasFailed(commit):
+			return status, ErrSyncFailed
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RequestSync asks the GitOps engine to sync the file at filePath right away. The resource asked is returned
+func (c *Cluster) RequestSync(ctx context.Context, filePath, requestedAt string) (string, error) {
+	if c.GitOps == nil {
+		return "", errors.New("no GitOps engine is configured")
+	}
+	return c.GitOps.RequestSync(ctx, filePath, requestedAt)
+}
+
+// WaitForSyncRequest polls the sync status every interval until the GitOps engine has handled the request at requestedAt, or ctx is done
+func (c *Cluster) WaitForSyncRequest(ctx context.Context, filePath, requestedAt string, interval time.Duration) (SyncStatus, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status, err := c.GetSyncStatus(ctx, filePath)
+		if err == nil && status.LastHandledRequest == requestedAt {
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return status, err
+			}
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// pathMatch is how a GitOps resource applies a file of kubernetes-configs
+type pathMatch struct {
+	// depth is how deep the path of the resource meets the dir of the file, which is the path itself if it contains the file, or the dir including the base of the file
+	depth int
+	// distance is how far the path of the resource is from where it meets the dir of the file, which is 0 if it contains the file
+	distance int
+}
+
+// isCloserThan reports whether the resource of m is closer to the file than the one of other. The deeper the match the closer, and then the less distant
+func (m pathMatch) isCloserThan(other pathMatch) bool {
+	if m.depth != other.depth {
+		return m.depth > other.depth
+	}
+	return m.distance < other.distance
+}
+
+// matchPath reports whether the GitOps resource at resourcePath applies a file in dir. A resource applies the files under its path. With the base and overlays layout of kubernetes-configs, it also applies the files of a base dir whose parent contains the path, as the overlays include ../base or ../../base
+func matchPath(dir, resourcePath string) (m pathMatch, isApplying bool) {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	resourcePath = strings.Trim(path.Clean("/"+resourcePath), "/")
+	if isUnder(dir, resourcePath) {
+		return pathMatch{depth: len(resourcePath)}, true
+	}
+	// the deepest base is the closest to the overlay
+	for base := dir; base != "." && base != "/"; base = path.Dir(base) {
+		if path.Base(base) != "base" {
+			continue
+		}
+		parent := path.Dir(base)
+		if parent == "." {
+			parent = ""
+		}
+		if isUnder(resourcePath, parent) && !isUnder(resourcePath, base) {
+			return pathMatch{depth: len(parent), distance: len(resourcePath) - len(parent)}, true
+		}
+	}
+	return pathMatch{}, false
+}
+
+// isUnder reports whether p is dir or under it. Every path is under the root, which is empty
+func isUnder(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
@@ -0,0 +1,94 @@
+package k8sop
+
+import "testing"
+
+func Test_revisionCommit(t *testing.T) {
+	for revision, want := range map[string]string{
+		"main@sha1:1bac23": "1bac23",
+		"main/1bac23":      "1bac23",
+		"1bac23":           "1bac23",
+	} {
+		if got := revisionCommit(revision); got != want {
+			t.Errorf("revisionCommit(%s) = %v, want %v", revision, got, want)
+		}
+	}
+}
+
+func Test_matchPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		dir          string
+		resourcePath string
+		want         pathMatch
+		wantApplying bool
+	}{
+		{name: "root", dir: "openwarehouse/overlays/dev", resourcePath: "./", want: pathMatch{}, wantApplying: true},
+		{name: "repo", dir: "openwarehouse/overlays/dev", resourcePath: "./openwarehouse", want: pathMatch{depth: len("openwarehouse")}, wantApplying: true},
+		{name: "overlay containing the file", dir: "openwarehouse/overlays/dev", resourcePath: "openwarehouse/overlays/dev/", want: pathMatch{depth: len("openwarehouse/overlays/dev")}, wantApplying: true},
+		{name: "another stage", dir: "openwarehouse/overlays/dev", resourcePath: "./openwarehouse/overlays/prod"},
+		{name: "prefix of the dir", dir: "openwarehouse/overlays/dev", resourcePath: "./openwarehouse/overlays/de"},
+		{
+			name:         "type 2 stage base included by a project overlay",
+			dir:          "openwarehouse/overlays/dev/base",
+			resourcePath: "./openwarehouse/overlays/dev/overlays/tv",
+			want:         pathMatch{depth: len("openwarehouse/overlays/dev"), distance: len("/overlays/tv")},
+			wantApplying: true,
+		},
+		{
+			name:         "type 2 project base included by a service overlay",
+			dir:          "openwarehouse/overlays/prod/overlays/tv/base",
+			resourcePath: "./openwarehouse/overlays/prod/overlays/tv/overlays/cms",
+			want:         pathMatch{depth: len("openwarehouse/overlays/prod/overlays/tv"), distance: len("/overlays/cms")},
+			wantApplying: true,
+		},
+		{
+			name:         "type 2 project base included by the overlay of the stage",
+			dir:          "openwarehouse/overlays/prod/overlays/tv/base",
+			resourcePath: "./openwarehouse/overlays/prod",
+			want:         pathMatch{depth: len("openwarehouse/overlays/prod")},
+			wantApplying: true,
+		},
+		{
+			name:         "type 2 project base of another project",
+			dir:          "openwarehouse/overlays/prod/overlays/tv/base",
+			resourcePath: "./openwarehouse/overlays/prod/overlays/readr/overlays/cms",
+		},
+		{
+			name:         "type 2 overlay of a service",
+			dir:          "openwarehouse/overlays/prod/overlays/tv/overlays/cms",
+			resourcePath: "./openwarehouse/overlays/prod/overlays/tv/overlays/cms",
+			want:         pathMatch{depth: len("openwarehouse/overlays/prod/overlays/tv/overlays/cms")},
+			wantApplying: true,
+		},
+		{
+			name:         "type 2 overlay of another service",
+			dir:          "openwarehouse/overlays/prod/overlays/tv/overlays/cms",
+			resourcePath: "./openwarehouse/overlays/prod/overlays/tv/overlays/gql-external",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, isApplying := matchPath(tt.dir, tt.resourcePath)
+			if got != tt.want || isApplying != tt.wantApplying {
+				t.Errorf("matchPath() = %+v, %v, want %+v, %v", got, isApplying, tt.want, tt.wantApplying)
+			}
+		})
+	}
+}
+
+func Test_pathMatch_isCloserThan(t *testing.T) {
+	containing := pathMatch{depth: len("openwarehouse/overlays/prod/overlays/tv")}
+	cms := pathMatch{depth: len("openwarehouse/overlays/prod/overlays/tv"), distance: len("/overlays/cms")}
+	gql := pathMatch{depth: len("openwarehouse/overlays/prod/overlays/tv"), distance: len("/overlays/gql-external")}
+	root := pathMatch{}
+	if !cms.isCloserThan(root) || root.isCloserThan(cms) {
+		t.Errorf("the overlay including the base should be closer than the root")
+	}
+	if !containing.isCloserThan(cms) || cms.isCloserThan(containing) {
+		t.Errorf("the path containing the file should be closer than the overlay including it")
+	}
+	if !cms.isCloserThan(gql) || gql.isCloserThan(cms) {
+		t.Errorf("the less distant overlay should be closer")
+	}
+}
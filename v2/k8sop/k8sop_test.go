@@ -1 +1,134 @@
 package k8sop
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://tv-prod.example.com
+  name: tv-prod
+- cluster:
+    server: https://tv-dev.example.com
+  name: tv-dev
+contexts:
+- context:
+    cluster: tv-prod
+    user: major-tom
+  name: tv-prod
+- context:
+    cluster: tv-dev
+    user: major-tom
+  name: tv-dev
+current-context: tv-dev
+users:
+- name: major-tom
+  user:
+    token: token
+`
+
+func writeTestKubeConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewClusterResolver(t *testing.T) {
+	path := writeTestKubeConfig(t)
+	tests := []struct {
+		name     string
+		clusters config.Clusters
+		wantErr  bool
+	}{
+		{
+			name: "valid contexts",
+			clusters: config.Clusters{
+				{Project: "tv", Stage: "prod", KubeConfigPath: path, Context: "tv-prod"},
+				{Project: "tv", Stage: "dev", KubeConfigPath: path},
+			},
+		},
+		{
+			name: "invalid registry",
+			clusters: config.Clusters{
+				{Project: "tv", KubeConfigPath: path},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing kubeconfig",
+			clusters: config.Clusters{
+				{Project: "tv", Stage: "prod", KubeConfigPath: filepath.Join(filepath.Dir(path), "missing")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing context",
+			clusters: config.Clusters{
+				{Project: "tv", Stage: "prod", KubeConfigPath: path, Context: "tv-staging"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClusterResolver(tt.clusters)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClusterResolver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterResolver_Resolve(t *testing.T) {
+	path := writeTestKubeConfig(t)
+	resolver, err := NewClusterResolver(config.Clusters{
+		{Project: "tv", Stage: "prod", KubeConfigPath: path, Context: "tv-prod", Namespace: "tv"},
+		{Project: "tv", Stage: "dev", KubeConfigPath: path, GitOps: config.GitOpsArgoCD},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, err := resolver.Resolve("openwarehouse", "tv", "prod")
+	if err != nil {
+		t.Fatalf("ClusterResolver.Resolve() error = %v", err)
+	}
+	if cluster.Namespace != "tv" {
+		t.Errorf("ClusterResolver.Resolve() namespace = %v, want %v", cluster.Namespace, "tv")
+	}
+	if flux, ok := cluster.GitOps.(Flux); !ok || flux.Namespace != config.DefaultFluxNamespace || cluster.Dynamic == nil {
+		t.Errorf("ClusterResolver.Resolve() GitOps = %+v, want Flux in %v with a dynamic client", cluster.GitOps, config.DefaultFluxNamespace)
+	}
+	if again, _ := resolver.Resolve("mirror-tv-nuxt", "tv", "prod"); again != cluster {
+		t.Errorf("ClusterResolver.Resolve() should reuse the client of the same cluster")
+	}
+
+	cluster, err = resolver.Resolve("openwarehouse", "tv", "dev")
+	if err != nil {
+		t.Fatalf("ClusterResolver.Resolve() error = %v", err)
+	}
+	if cluster.Namespace != config.DefaultNamespace {
+		t.Errorf("ClusterResolver.Resolve() namespace = %v, want %v", cluster.Namespace, config.DefaultNamespace)
+	}
+	if argoCD, ok := cluster.GitOps.(ArgoCD); !ok || argoCD.Namespace != config.DefaultArgoCDNamespace {
+		t.Errorf("ClusterResolver.Resolve() GitOps = %+v, want Argo CD in %v", cluster.GitOps, config.DefaultArgoCDNamespace)
+	}
+
+	if _, err := resolver.Resolve("openwarehouse", "readr", "prod"); err == nil {
+		t.Errorf("ClusterResolver.Resolve() should fail without a cluster")
+	}
+
+	var nilResolver *ClusterResolver
+	if _, err := nilResolver.Resolve("openwarehouse", "tv", "prod"); err == nil {
+		t.Errorf("ClusterResolver.Resolve() should fail without a registry")
+	}
+}
@@ -23,9 +23,17 @@ type DeploymentInfo struct {
 	Available int32
 	ImageTag  string
 	Ready     int32
+	Replicas  int32
 	Updated   int32
 }
 
+type HPAInfo struct {
+	Current     int32
+	Desired     int32
+	MaxReplicas int32
+	MinReplicas int32
+}
+
 func getKubeCliSet(kubeConfigPath string, namespace string) (clientset *kubernetes.Clientset, err error) {
 	// Initialize kubernetes-client
 	config, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
@@ -112,29 +120,12 @@ func listReleases(ctx context.Context, kubeConfigPath string, namespace string)
 	return releaseInfo, nil
 }
 
-// GetDeploymentInfo return the status of current deployments for the specific service
-func GetDeploymentInfo(ctx context.Context, kubeConfigPath string, name string) (DeploymentInfo, error) {
-	list, err := ListReleases(ctx, kubeConfigPath)
-	if err != nil {
-		return DeploymentInfo{}, err
-	}
-	namespaces := make(map[string]string, len(list))
-	for _, release := range list {
-		namespaces[release.Name] = release.Namespace
-	}
-	namespace, isExisting := namespaces[name]
-	if !isExisting {
-		return DeploymentInfo{}, errors.Errorf("service(%s) doesn't exist or isn't operable", name)
-	}
-	return getDeploymentInfo(ctx, kubeConfigPath, namespace, name)
+// GetDeploymentInfo return the status of current deployment for the specific service
+func (c *Cluster) GetDeploymentInfo(ctx context.Context, name string) (DeploymentInfo, error) {
+	return getDeploymentInfo(ctx, c.Clientset, c.Namespace, name)
 }
 
-func getDeploymentInfo(ctx context.Context, kubeConfigPath string, namespace string, name string) (DeploymentInfo, error) {
-
-	clientset, err := getKubeCliSet(kubeConfigPath, namespace)
-	if err != nil {
-		return DeploymentInfo{}, err
-	}
+func getDeploymentInfo(ctx context.Context, clientset kubernetes.Interface, namespace string, name string) (DeploymentInfo, error) {
 
 	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, v1.GetOptions{
 		TypeMeta: v1.TypeMeta{
@@ -146,17 +137,51 @@ func getDeploymentInfo(ctx context.Context, kubeConfigPath string, namespace str
 	}
 
 	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return DeploymentInfo{}, errors.Errorf("deployment(%s) has no container", name)
+	}
 	imageParts := strings.Split(containers[0].Image, ":")
 
+	var replicas int32
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
 	return DeploymentInfo{
 		Available: deployment.Status.AvailableReplicas,
 		ImageTag:  imageParts[len(imageParts)-1],
 		Ready:     deployment.Status.ReadyReplicas,
+		Replicas:  replicas,
 		Updated:   deployment.Status.UpdatedReplicas,
 	}, nil
 
 }
 
+// GetHPAInfo return the autoscaling status of the specific service
+func (c *Cluster) GetHPAInfo(ctx context.Context, name string) (HPAInfo, error) {
+	return getHPAInfo(ctx, c.Clientset, c.Namespace, name)
+}
+
+func getHPAInfo(ctx context.Context, clientset kubernetes.Interface, namespace string, name string) (HPAInfo, error) {
+	hpa, err := clientset.AutoscalingV1().HorizontalPodAutoscalers(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return HPAInfo{}, err
+	}
+
+	// minReplicas defaults to 1 in Kubernetes
+	minReplicas := int32(1)
+	if hpa.Spec.MinReplicas != nil {
+		minReplicas = *hpa.Spec.MinReplicas
+	}
+
+	return HPAInfo{
+		Current:     hpa.Status.CurrentReplicas,
+		Desired:     hpa.Status.DesiredReplicas,
+		MaxReplicas: hpa.Spec.MaxReplicas,
+		MinReplicas: minReplicas,
+	}, nil
+}
+
 func getPodInfo(ctx context.Context, kubeConfigPath string, namespace string, name string) (map[string]int, error) {
 
 	clientset, err := getKubeCliSet(kubeConfigPath, namespace)
@@ -1 +1,138 @@
 package k8sop
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func Test_getDeploymentInfo(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Name: "openwarehouse-tv-cms", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: int32Ptr(3),
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Image: "gcr.io/mirror-tv/openwarehouse:prod_81ab7ac"}},
+					},
+				},
+			},
+			Status: appsv1.DeploymentStatus{
+				AvailableReplicas: 2,
+				ReadyReplicas:     2,
+				UpdatedReplicas:   1,
+			},
+		},
+	)
+	tests := []struct {
+		name      string
+		namespace string
+		service   string
+		want      DeploymentInfo
+		wantErr   bool
+	}{
+		{
+			name:      "existing deployment",
+			namespace: "default",
+			service:   "openwarehouse-tv-cms",
+			want: DeploymentInfo{
+				Available: 2,
+				ImageTag:  "prod_81ab7ac",
+				Ready:     2,
+				Replicas:  3,
+				Updated:   1,
+			},
+		},
+		{
+			name:      "deployment in another namespace",
+			namespace: "cron",
+			service:   "openwarehouse-tv-cms",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getDeploymentInfo(context.TODO(), clientset, tt.namespace, tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getDeploymentInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getDeploymentInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getHPAInfo(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&autoscalingv1.HorizontalPodAutoscaler{
+			ObjectMeta: v1.ObjectMeta{Name: "openwarehouse-tv-cms", Namespace: "default"},
+			Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+				MinReplicas: int32Ptr(2),
+				MaxReplicas: 5,
+			},
+			Status: autoscalingv1.HorizontalPodAutoscalerStatus{
+				CurrentReplicas: 3,
+				DesiredReplicas: 4,
+			},
+		},
+		&autoscalingv1.HorizontalPodAutoscaler{
+			ObjectMeta: v1.ObjectMeta{Name: "mirror-tv-nuxt", Namespace: "default"},
+			Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+				MaxReplicas: 2,
+			},
+		},
+	)
+	tests := []struct {
+		name    string
+		service string
+		want    HPAInfo
+		wantErr bool
+	}{
+		{
+			name:    "existing hpa",
+			service: "openwarehouse-tv-cms",
+			want: HPAInfo{
+				Current:     3,
+				Desired:     4,
+				MaxReplicas: 5,
+				MinReplicas: 2,
+			},
+		},
+		{
+			name:    "minReplicas defaults to 1",
+			service: "mirror-tv-nuxt",
+			want: HPAInfo{
+				MaxReplicas: 2,
+				MinReplicas: 1,
+			},
+		},
+		{
+			name:    "no hpa",
+			service: "openwarehouse-tv-gql-external",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getHPAInfo(context.TODO(), clientset, "default", tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getHPAInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getHPAInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package k8sop
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ReasonCrashLoopBackOff is the reason of the containers restarting after crashes
+const ReasonCrashLoopBackOff = "CrashLoopBackOff"
+
+// ErrRolloutFailed is returned by WaitForRollout if the pods wait for a reason it fails on
+var ErrRolloutFailed = errors.New("rollout failed")
+
+// Rollout is the progress of a deployment towards the image tags
+type Rollout struct {
+	// IsImageSet is true if the pod template has the image tags and the controller has observed it
+	IsImageSet bool
+	Replicas   int32
+	Updated    int32
+	Available  int32
+	// Reasons are why the containers of the pods are waiting, like CrashLoopBackOff or ImagePullBackOff
+	Reasons []string
+}
+
+// IsDone reports whether all the replicas are updated and available
+func (r Rollout) IsDone() bool {
+	return r.IsImageSet && r.Updated == r.Replicas && r.Available == r.Replicas
+}
+
+// HasReason reports whether a container is waiting for the reason
+func (r Rollout) HasReason(reason string) bool {
+	for _, s := range r.Reasons {
+		if strings.HasSuffix(s, ": "+reason) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rollout) String() string {
+	s := fmt.Sprintf("%d/%d updated, %d/%d available", r.Updated, r.Replicas, r.Available, r.Replicas)
+	if !r.IsImageSet {
+		s = "image tag is not set yet, " + s
+	}
+	if len(r.Reasons) != 0 {
+		s += "; " + strings.Join(r.Reasons, "; ")
+	}
+	return s
+}
+
+// GetRollout returns the progress of the deployment towards tags, which are the image tags keyed by the image names
+func (c *Cluster) GetRollout(ctx context.Context, name string, tags map[string]string) (Rollout, error) {
+	return getRollout(ctx, c.Clientset, c.Namespace, name, tags)
+}
+
+// WaitForRollout polls the deployment every interval until it's rolled out to tags or ctx is done. The last progress is returned with the error of ctx, or with ErrRolloutFailed as soon as a container waits for any of failOn
+func (c *Cluster) WaitForRollout(ctx context.Context, name string, tags map[string]string, interval time.Duration, failOn ...string) (Rollout, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		rollout, err := c.GetRollout(ctx, name, tags)
+		if err == nil && rollout.IsDone() {
+			return rollout, nil
+		}
+		for _, reason := range failOn {
+			if rollout.HasReason(reason) {
+				return rollout, ErrRolloutFailed
+			}
+		}
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return rollout, err
+			}
+			return rollout, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func getRollout(ctx context.Context, clientset kubernetes.Interface, namespace string, name string, tags map[string]string) (Rollout, error) {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return Rollout{}, err
+	}
+
+	// replicas defaults to 1 in Kubernetes
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	rollout := Rollout{
+		IsImageSet: deployment.Status.ObservedGeneration >= deployment.Generation,
+		Replicas:   replicas,
+		Updated:    deployment.Status.UpdatedReplicas,
+		Available:  deployment.Status.AvailableReplicas,
+	}
+
+	var matched int
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		image, tag := splitImage(container.Image)
+		want, isExisting := tags[image]
+		if !isExisting {
+			continue
+		}
+		matched++
+		if tag != want {
+			rollout.IsImageSet = false
+		}
+	}
+	if matched == 0 {
+		return rollout, errors.Errorf("deployment(%s) has no container of the images", name)
+	}
+	if rollout.IsDone() {
+		return rollout, nil
+	}
+
+	selector, err := v1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return rollout, errors.Wrap(err, fmt.Sprintf("selector of deployment(%s) is invalid", name))
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, v1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return rollout, errors.Wrap(err, fmt.Sprintf("listing pods of deployment(%s) has error", name))
+	}
+	for _, pod := range pods.Items {
+		// the pods of the previous image tags don't tell how the rollout goes
+		if isOutdated(pod.Spec.Containers, tags) {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason != "" && status.State.Waiting.Reason != "ContainerCreating" {
+				rollout.Reasons = append(rollout.Reasons, fmt.Sprintf("pod(%s) container(%s): %s", pod.Name, status.Name, status.State.Waiting.Reason))
+			}
+		}
+	}
+	sort.Strings(rollout.Reasons)
+	return rollout, nil
+}
+
+// isOutdated reports whether a container of the images has another tag
+func isOutdated(containers []corev1.Container, tags map[string]string) bool {
+	for _, container := range containers {
+		image, tag := splitImage(container.Image)
+		if want, isExisting := tags[image]; isExisting && tag != want {
+			return true
+		}
+	}
+	return false
+}
+
+// splitImage splits the image of a container into its name and its tag. The registry port isn't taken as a tag, and the digest is dropped
+func splitImage(image string) (name, tag string) {
+	if i := strings.IndexByte(image, '@'); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndexByte(image, ':')
+	if i < 0 || strings.ContainsRune(image[i:], '/') {
+		return image, ""
+	}
+	return image[:i], image[i+1:]
+}
@@ -0,0 +1,130 @@
+package k8sop
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newRolloutDeployment(image string, updated, available int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{Name: "openwarehouse", Namespace: "default", Generation: 2},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: int32Ptr(2),
+			Selector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "openwarehouse"}},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Image: image},
+						{Image: "gcr.io/cloudsql-docker/gce-proxy:1.23"},
+					},
+				},
+			},
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			UpdatedReplicas:    updated,
+			AvailableReplicas:  available,
+		},
+	}
+}
+
+func Test_getRollout(t *testing.T) {
+	crashing := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "openwarehouse-6d4b9", Namespace: "default", Labels: map[string]string{"app": "openwarehouse"}},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "openwarehouse",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+			}},
+		},
+	}
+	crashed := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "openwarehouse-5c8f7", Namespace: "default", Labels: map[string]string{"app": "openwarehouse"}},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "openwarehouse", Image: "gcr.io/mirrormedia-1470651750304/openwarehouse:dev_0abc12"}},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "openwarehouse",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+			}},
+		},
+	}
+	tags := map[string]string{"gcr.io/mirrormedia-1470651750304/openwarehouse": "dev_1bac23"}
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		wantDone   bool
+		want       Rollout
+		wantErr    bool
+	}{
+		{
+			name:       "rolled out",
+			deployment: newRolloutDeployment("gcr.io/mirrormedia-1470651750304/openwarehouse:dev_1bac23", 2, 2),
+			wantDone:   true,
+			want:       Rollout{IsImageSet: true, Replicas: 2, Updated: 2, Available: 2},
+		},
+		{
+			name:       "crashing",
+			deployment: newRolloutDeployment("gcr.io/mirrormedia-1470651750304/openwarehouse:dev_1bac23", 1, 1),
+			want:       Rollout{IsImageSet: true, Replicas: 2, Updated: 1, Available: 1, Reasons: []string{"pod(openwarehouse-6d4b9) container(openwarehouse): CrashLoopBackOff"}},
+		},
+		{
+			name:       "old image tag",
+			deployment: newRolloutDeployment("gcr.io/mirrormedia-1470651750304/openwarehouse:dev_0abc12", 2, 2),
+			want:       Rollout{Replicas: 2, Updated: 2, Available: 2, Reasons: []string{"pod(openwarehouse-6d4b9) container(openwarehouse): CrashLoopBackOff"}},
+		},
+		{
+			name:       "no container of the image",
+			deployment: newRolloutDeployment("gcr.io/mirrormedia-1470651750304/yt-relay:dev_1bac23", 2, 2),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.deployment, crashing, crashed)
+			got, err := getRollout(context.Background(), clientset, "default", "openwarehouse", tags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getRollout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.IsDone() != tt.wantDone {
+				t.Errorf("Rollout.IsDone() = %v, want %v", got.IsDone(), tt.wantDone)
+			}
+			if got.HasReason(ReasonCrashLoopBackOff) != (len(tt.want.Reasons) != 0) {
+				t.Errorf("Rollout.HasReason() = %v", got.HasReason(ReasonCrashLoopBackOff))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRollout() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_splitImage(t *testing.T) {
+	tests := []struct {
+		image    string
+		wantName string
+		wantTag  string
+	}{
+		{image: "gcr.io/mirrormedia-1470651750304/openwarehouse:dev_1bac23", wantName: "gcr.io/mirrormedia-1470651750304/openwarehouse", wantTag: "dev_1bac23"},
+		{image: "localhost:5000/openwarehouse", wantName: "localhost:5000/openwarehouse"},
+		{image: "localhost:5000/openwarehouse:dev_1bac23@sha256:0abc", wantName: "localhost:5000/openwarehouse", wantTag: "dev_1bac23"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			name, tag := splitImage(tt.image)
+			if name != tt.wantName || tag != tt.wantTag {
+				t.Errorf("splitImage() = %v, %v, want %v, %v", name, tag, tt.wantName, tt.wantTag)
+			}
+		})
+	}
+}
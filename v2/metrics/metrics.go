@@ -0,0 +1,69 @@
+// Package metrics defines the Prometheus metrics of the bot, which are registered to the default registry
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "major_tom"
+
+// Phases of a deployment in the deploy worker
+const (
+	PhasePull   = "pull"
+	PhaseEdit   = "edit"
+	PhaseCommit = "commit"
+	PhasePush   = "push"
+)
+
+var (
+	// Commands counts the commands by the verb and the outcome
+	Commands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_total",
+		Help:      "Number of the commands by verb and outcome.",
+	}, []string{"verb", "outcome"})
+
+	// DeployPhaseDuration observes how long each phase of a deployment takes in the deploy worker
+	DeployPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "deploy_phase_duration_seconds",
+		Help:      "Duration of the pull, edit, commit and push phases of the deployments.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"phase"})
+
+	// GitPushFailures counts the pushes to kubernetes-configs which failed
+	GitPushFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "git_push_failures_total",
+		Help:      "Number of the failed pushes to kubernetes-configs.",
+	})
+
+	// SlackReconnects counts the reconnections of the Slack socket after the first connection
+	SlackReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_reconnects_total",
+		Help:      "Number of the reconnections of the Slack socket mode connection.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(Commands, DeployPhaseDuration, GitPushFailures, SlackReconnects)
+}
+
+// ObservePhase observes the duration of the phase since start
+func ObservePhase(phase string, start time.Time) {
+	DeployPhaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+// RegisterDeployQueue registers the gauge of the deployments waiting in the queue of the deploy worker, which is measured by depth when it's scraped
+func RegisterDeployQueue(depth func() int) error {
+	return prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "deploy_queue_depth",
+		Help:      "Number of the deployments waiting for the deploy worker.",
+	}, func() float64 {
+		return float64(depth())
+	}))
+}
@@ -0,0 +1,166 @@
+// Package pending keeps the operations waiting for a confirmation or an approval before they are carried out
+package pending
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotFound     = errors.New("the request is not found or has expired")
+	ErrForbidden    = errors.New("only the caller or an approver can answer the request")
+	ErrNotApprover  = errors.New("only an approver can approve the request")
+	ErrSelfApproval = errors.New("the request must be approved by someone other than the caller")
+)
+
+// Field is a line of the summary of a request
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Request is an operation waiting for its caller, or an approver, to carry it out
+type Request struct {
+	ID         string
+	Title      string
+	Fields     []Field
+	Repo       string
+	Stage      string
+	CallerID   string
+	CallerName string
+	ChannelID  string
+	// TwoPerson requires an approver other than the caller
+	TwoPerson bool
+	ExpireAt  time.Time
+	// Run carries out the operation
+	Run func(ctx context.Context) (messages []string, err error)
+}
+
+// Store holds the requests until they are answered or expired
+type Store struct {
+	locker    sync.Mutex
+	requests  map[string]Request
+	timeout   time.Duration
+	approvers map[string]bool
+	twoPerson bool
+	now       func() time.Time
+}
+
+// NewStore creates a store whose requests expire after timeout. approvers are the user IDs allowed to answer any request. twoPerson tells the callers to hold the requests which need two persons, which can only be approved by an approver other than their callers
+func NewStore(timeout time.Duration, approvers []string, twoPerson bool) *Store {
+	s := &Store{
+		requests:  make(map[string]Request),
+		timeout:   timeout,
+		approvers: make(map[string]bool, len(approvers)),
+		twoPerson: twoPerson,
+		now:       time.Now,
+	}
+	for _, approver := range approvers {
+		s.approvers[approver] = true
+	}
+	return s
+}
+
+// TwoPerson reports whether two persons are required by the requests of a kind, like prod releases and scales
+func (s *Store) TwoPerson() bool {
+	return s.twoPerson
+}
+
+// Add stores the request with a new ID and its expiry
+func (s *Store) Add(r Request) (Request, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return r, errors.Wrap(err, "generating request id has error")
+	}
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.prune()
+	r.ID = hex.EncodeToString(b)
+	r.ExpireAt = s.now().Add(s.timeout)
+	s.requests[r.ID] = r
+	return r, nil
+}
+
+// List returns the requests which are not expired, the earliest expiry first
+func (s *Store) List() []Request {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.prune()
+	requests := make([]Request, 0, len(s.requests))
+	for _, r := range s.requests {
+		requests = append(requests, r)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].ExpireAt.Before(requests[j].ExpireAt)
+	})
+	return requests
+}
+
+// Get returns the request which is not expired
+func (s *Store) Get(id string) (Request, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.prune()
+	r, ok := s.requests[id]
+	if !ok {
+		return r, ErrNotFound
+	}
+	return r, nil
+}
+
+// Approve removes the request so it's carried out only once. The user has to be the caller or an approver, or an approver other than the caller if the request requires two persons
+func (s *Store) Approve(id, userID string) (Request, error) {
+	return s.take(id, func(r Request) error {
+		switch {
+		case !r.TwoPerson && userID != r.CallerID && !s.approvers[userID]:
+			return ErrForbidden
+		case r.TwoPerson && userID == r.CallerID:
+			return ErrSelfApproval
+		case r.TwoPerson && !s.approvers[userID]:
+			return ErrNotApprover
+		}
+		return nil
+	})
+}
+
+// Cancel removes the request. The user has to be the caller or an approver
+func (s *Store) Cancel(id, userID string) (Request, error) {
+	return s.take(id, func(r Request) error {
+		if userID != r.CallerID && !s.approvers[userID] {
+			return ErrForbidden
+		}
+		return nil
+	})
+}
+
+func (s *Store) take(id string, isAllowed func(r Request) error) (Request, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.prune()
+	r, ok := s.requests[id]
+	if !ok {
+		return r, ErrNotFound
+	}
+	if err := isAllowed(r); err != nil {
+		return r, err
+	}
+	delete(s.requests, id)
+	return r, nil
+}
+
+// prune removes the expired requests. The caller must hold the lock
+func (s *Store) prune() {
+	now := s.now()
+	for id, r := range s.requests {
+		if !now.Before(r.ExpireAt) {
+			delete(s.requests, id)
+		}
+	}
+}
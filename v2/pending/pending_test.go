@@ -0,0 +1,138 @@
+package pending
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStore_Approve(t *testing.T) {
+	now := time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		twoPerson bool
+		userID    string
+		elapsed   time.Duration
+		unknown   bool
+		wantErr   error
+	}{
+		{
+			name:   "caller",
+			userID: "U-caller",
+		},
+		{
+			name:   "approver",
+			userID: "U-approver",
+		},
+		{
+			name:    "someone else",
+			userID:  "U-someone",
+			wantErr: ErrForbidden,
+		},
+		{
+			name:      "another approver for two persons",
+			twoPerson: true,
+			userID:    "U-approver",
+		},
+		{
+			name:      "caller for two persons",
+			twoPerson: true,
+			userID:    "U-caller",
+			wantErr:   ErrSelfApproval,
+		},
+		{
+			name:      "someone else for two persons",
+			twoPerson: true,
+			userID:    "U-someone",
+			wantErr:   ErrNotApprover,
+		},
+		{
+			name:    "expired",
+			userID:  "U-caller",
+			elapsed: 5 * time.Minute,
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "unknown id",
+			userID:  "U-caller",
+			unknown: true,
+			wantErr: ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the store requires two persons for some requests only, which is up to the request
+			s := NewStore(5*time.Minute, []string{"U-approver"}, true)
+			s.now = func() time.Time { return now }
+			r, err := s.Add(Request{CallerID: "U-caller", TwoPerson: tt.twoPerson})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := now.Add(5 * time.Minute); !r.ExpireAt.Equal(want) {
+				t.Errorf("Store.Add() ExpireAt = %v, want %v", r.ExpireAt, want)
+			}
+
+			s.now = func() time.Time { return now.Add(tt.elapsed) }
+			id := r.ID
+			if tt.unknown {
+				id = "unknown"
+			}
+			got, err := s.Approve(id, tt.userID)
+			if err != tt.wantErr {
+				t.Fatalf("Store.Approve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.ID != r.ID {
+				t.Errorf("Store.Approve() ID = %v, want %v", got.ID, r.ID)
+			}
+			// a request is answered only once
+			if _, err := s.Approve(id, tt.userID); err != ErrNotFound {
+				t.Errorf("Store.Approve() again error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestStore_Cancel(t *testing.T) {
+	s := NewStore(5*time.Minute, []string{"U-approver"}, true)
+	r, err := s.Add(Request{CallerID: "U-caller", TwoPerson: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Cancel(r.ID, "U-someone"); err != ErrForbidden {
+		t.Errorf("Store.Cancel() error = %v, want %v", err, ErrForbidden)
+	}
+	// the caller can cancel the request it can't approve
+	if _, err := s.Cancel(r.ID, "U-caller"); err != nil {
+		t.Errorf("Store.Cancel() error = %v", err)
+	}
+	if got := s.List(); len(got) != 0 {
+		t.Errorf("Store.List() = %v, want none", got)
+	}
+}
+
+func TestStore_List(t *testing.T) {
+	now := time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC)
+	s := NewStore(5*time.Minute, nil, false)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		s.now = func() time.Time { return now.Add(time.Duration(i) * time.Minute) }
+		r, err := s.Add(Request{Title: "request"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, r.ID)
+	}
+
+	// the first one expires at 12:05
+	s.now = func() time.Time { return now.Add(5 * time.Minute) }
+	var got []string
+	for _, r := range s.List() {
+		got = append(got, r.ID)
+	}
+	if want := ids[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Store.List() = %v, want %v", got, want)
+	}
+}
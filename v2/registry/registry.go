@@ -0,0 +1,230 @@
+// Package registry verifies image tags in the container registries over the Docker Registry HTTP API v2
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/pkg/errors"
+)
+
+// dockerHub is the host of the images without a registry in their names
+const dockerHub = "registry-1.docker.io"
+
+// manifestTypes are the media types of the manifests a tag can point to
+var manifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v1+prettyjws",
+}
+
+// Client tells whether an image tag exists in its registry
+type Client interface {
+	// HasTag reports whether the tag of the image exists. name is the image name like gcr.io/mirrormedia-1470651750304/openwarehouse
+	HasTag(ctx context.Context, name, tag string) (bool, error)
+}
+
+type credential struct {
+	username string
+	password string
+}
+
+type httpClient struct {
+	client      *http.Client
+	credentials map[string]credential
+	plainHTTP   map[string]bool
+}
+
+// NewClient returns a client of the registries with the credentials in the config. The registries ask for a basic auth or a bearer token issued by their token services
+func NewClient(cfg config.Registry) (Client, error) {
+	c := &httpClient{
+		client:      &http.Client{Timeout: 30 * time.Second},
+		credentials: make(map[string]credential),
+		plainHTTP:   make(map[string]bool),
+	}
+	for _, cred := range cfg.Credentials {
+		password := cred.Password
+		if password == "" && cred.PasswordFile != "" {
+			b, err := ioutil.ReadFile(cred.PasswordFile)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("reading password file of registry(%s) has error", cred.Host))
+			}
+			password = strings.TrimSpace(string(b))
+		}
+		c.credentials[cred.Host] = credential{username: cred.Username, password: password}
+	}
+	for _, host := range cfg.PlainHTTP {
+		c.plainHTTP[host] = true
+	}
+	return c, nil
+}
+
+// splitName splits the image name into the host of its registry and the repository in the registry
+func splitName(name string) (host, repository string) {
+	i := strings.IndexByte(name, '/')
+	if i < 0 || (!strings.ContainsAny(name[:i], ".:") && name[:i] != "localhost") {
+		if i < 0 {
+			return dockerHub, "library/" + name
+		}
+		return dockerHub, name
+	}
+	return name[:i], name[i+1:]
+}
+
+func (c *httpClient) HasTag(ctx context.Context, name, tag string) (bool, error) {
+	host, repository := splitName(name)
+	scheme := "https"
+	if c.plainHTTP[host] {
+		scheme = "http"
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, repository, tag)
+
+	resp, err := c.head(ctx, manifestURL, "")
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization, err := c.authorize(ctx, host, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return false, errors.Wrap(err, fmt.Sprintf("authenticating to registry(%s) has error", host))
+		}
+		resp, err = c.head(ctx, manifestURL, authorization)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errors.Errorf("registry(%s) responded %s for %s:%s", host, resp.Status, repository, tag)
+	}
+}
+
+func (c *httpClient) head(ctx context.Context, manifestURL, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("requesting %s has error", manifestURL))
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// authorize answers the challenge of the registry with the Authorization header
+func (c *httpClient) authorize(ctx context.Context, host, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	cred, hasCredential := c.credentials[host]
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCredential {
+			return "", errors.New("credential is required")
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(cred.username+":"+cred.password)), nil
+	case "bearer":
+	default:
+		return "", errors.Errorf("challenge(%s) is not supported", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", errors.Errorf("realm of challenge(%s) is invalid", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCredential {
+		req.SetBasicAuth(cred.username, cred.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("requesting token from %s has error", realm.Host))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("token service(%s) responded %s", realm.Host, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("decoding token from %s has error", realm.Host))
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", errors.Errorf("token service(%s) issued no token", realm.Host)
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge parses the WWW-Authenticate header like Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+func parseChallenge(challenge string) (scheme string, params map[string]string) {
+	params = make(map[string]string)
+	challenge = strings.TrimSpace(challenge)
+	i := strings.IndexByte(challenge, ' ')
+	if i < 0 {
+		return challenge, params
+	}
+	scheme, rest := challenge[:i], challenge[i+1:]
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return scheme, params
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// quoted values like the scope can have commas
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+		params[strings.ToLower(key)] = value
+	}
+}
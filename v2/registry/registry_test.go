@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+)
+
+// newRegistry starts a registry stand-in having openwarehouse:prod_399440e, which issues tokens to the user major-tom
+func newRegistry(t *testing.T) (host string) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	host = strings.TrimPrefix(server.URL, "http://")
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "major-tom" || password != "ground-control" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("scope") != "repository:mirrormedia/openwarehouse:pull" || r.URL.Query().Get("service") != "stand-in" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"token": "secret"}`)
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="stand-in",scope="repository:mirrormedia/openwarehouse:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodHead || r.URL.Path != "/v2/mirrormedia/openwarehouse/manifests/prod_399440e" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	return host
+}
+
+func TestClient_HasTag(t *testing.T) {
+	host := newRegistry(t)
+	tests := []struct {
+		name     string
+		password string
+		image    string
+		tag      string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:     "existing tag",
+			password: "ground-control",
+			image:    host + "/mirrormedia/openwarehouse",
+			tag:      "prod_399440e",
+			want:     true,
+		},
+		{
+			name:     "typo in the tag",
+			password: "ground-control",
+			image:    host + "/mirrormedia/openwarehouse",
+			tag:      "prod_399440f",
+		},
+		{
+			name:     "wrong password",
+			password: "major-tom",
+			image:    host + "/mirrormedia/openwarehouse",
+			tag:      "prod_399440e",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(config.Registry{
+				Credentials: []config.RegistryCredential{{Host: host, Username: "major-tom", Password: tt.password}},
+				PlainHTTP:   []string{host},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.HasTag(context.Background(), tt.image, tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Client.HasTag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Client.HasTag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_splitName(t *testing.T) {
+	tests := []struct {
+		name           string
+		wantHost       string
+		wantRepository string
+	}{
+		{
+			name:           "gcr.io/mirrormedia-1470651750304/openwarehouse",
+			wantHost:       "gcr.io",
+			wantRepository: "mirrormedia-1470651750304/openwarehouse",
+		},
+		{
+			name:           "localhost:5000/openwarehouse",
+			wantHost:       "localhost:5000",
+			wantRepository: "openwarehouse",
+		},
+		{
+			name:           "mirrormedia/openwarehouse",
+			wantHost:       dockerHub,
+			wantRepository: "mirrormedia/openwarehouse",
+		},
+		{
+			name:           "nginx",
+			wantHost:       dockerHub,
+			wantRepository: "library/nginx",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, repository := splitName(tt.name)
+			if host != tt.wantHost || repository != tt.wantRepository {
+				t.Errorf("splitName() = %v, %v, want %v, %v", host, repository, tt.wantHost, tt.wantRepository)
+			}
+		})
+	}
+}
+
+func Test_parseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull,push"`)
+	if scheme != "Bearer" {
+		t.Errorf("parseChallenge() scheme = %v, want Bearer", scheme)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull,push",
+	}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("parseChallenge() %s = %v, want %v", key, params[key], value)
+		}
+	}
+}
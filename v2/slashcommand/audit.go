@@ -0,0 +1,133 @@
+package slashcommand
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/audit"
+	"github.com/mirror-media/major-tom-go/v2/command"
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/metrics"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultAuditLimit is the number of entries returned by the audit command by default
+const defaultAuditLimit = 10
+
+// newEntry parses the text of the command for the audit entry as Run does
+func newEntry(k8sRepoConfig config.KubernetesConfigsRepo, txt string, caller Caller) audit.Entry {
+	cmd, args := parseText(txt)
+	e := audit.Entry{
+		UserID:    caller.ID,
+		User:      caller.Name,
+		ChannelID: caller.ChannelID,
+		Text:      txt,
+		Command:   cmd,
+		Args:      args,
+	}
+	e.Repo, e.Stage = command.Target(k8sRepoConfig, cmd, args)
+	if e.Repo != "" {
+		e.Target = args[0]
+	}
+	return e
+}
+
+// outcome tells how the command ended
+func outcome(trace *audit.Trace, isPending bool, err error) string {
+	switch {
+	case trace.IsDenied():
+		return audit.OutcomeDenied
+	case command.IsTimeout(err):
+		return audit.OutcomeTimeout
+	case err != nil:
+		return audit.OutcomeError
+	case isPending:
+		return audit.OutcomePending
+	default:
+		return audit.OutcomeOK
+	}
+}
+
+// verbLabel is the verb of the command in the metrics. The unknown ones are counted as other to bound the labels
+func verbLabel(cmd string) string {
+	if verbs[cmd] || cmd == "approve" {
+		return cmd
+	}
+	return "other"
+}
+
+// record counts the command in the metrics and writes the entry to the sink with what the trace collected and how the command ended. A failure to record is logged and doesn't fail the command
+func record(sink audit.Sink, e audit.Entry, trace *audit.Trace, start time.Time, isPending bool, err error) {
+	e.Outcome = outcome(trace, isPending, err)
+	metrics.Commands.WithLabelValues(verbLabel(e.Command), e.Outcome).Inc()
+	if sink == nil {
+		return
+	}
+	e.Time = start
+	e.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		e.Error = err.Error()
+	}
+	trace.Fill(&e)
+
+	if errWrite := sink.Write(e); errWrite != nil {
+		logrus.Error(errors.Wrap(errWrite, "recording the command to the audit log has error"))
+	}
+}
+
+// queryAudit reports the latest entries in the audit log. texts is interpreted as [service=value, user=value, limit=value], all of which are optional
+func queryAudit(sink audit.Sink, texts []string) (messages []string, err error) {
+	querier, ok := sink.(audit.Querier)
+	if !ok {
+		return nil, errors.New("audit log is not enabled")
+	}
+
+	q := audit.Query{Limit: defaultAuditLimit}
+	for _, text := range texts {
+		pair := strings.SplitN(text, "=", 2)
+		if len(pair) != 2 {
+			return nil, errors.New("Major Tom does not support: " + text)
+		}
+		switch pair[0] {
+		case "service":
+			q.Service = pair[1]
+		case "user":
+			q.User = strings.TrimPrefix(pair[1], "@")
+		case "limit":
+			q.Limit, err = strconv.Atoi(pair[1])
+			if err != nil || q.Limit < 1 {
+				return nil, errors.Errorf("limit(%s) should be a positive number", pair[1])
+			}
+		default:
+			return nil, errors.New("Major Tom does not support: " + text)
+		}
+	}
+
+	entries, err := querier.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return []string{"no command is found in the audit log"}, nil
+	}
+	return formatEntries(entries), nil
+}
+
+// formatEntries lists the entries, one line each
+func formatEntries(entries []audit.Entry) []string {
+	messages := make([]string, 0, len(entries))
+	for i, e := range entries {
+		line := fmt.Sprintf("%d. %s %s `%s` %s in %s", i+1, e.Time.Format("2006-01-02 15:04:05"), e.User, e.Text, e.Outcome, time.Duration(e.DurationMS)*time.Millisecond)
+		if e.Commit != "" {
+			line += ", commit " + e.Commit
+		}
+		if e.Error != "" {
+			line += ": " + e.Error
+		}
+		messages = append(messages, line)
+	}
+	return messages
+}
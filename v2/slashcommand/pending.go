@@ -0,0 +1,236 @@
+package slashcommand
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/audit"
+	"github.com/mirror-media/major-tom-go/v2/command"
+	"github.com/mirror-media/major-tom-go/v2/config"
+	mjcontext "github.com/mirror-media/major-tom-go/v2/internal/context"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"github.com/mirror-media/major-tom-go/v2/pending"
+)
+
+// release holds the release until it's confirmed, unless it's a dry run which changes nothing
+func release(ctx context.Context, requests *pending.Store, clusters *k8sop.ClusterResolver, k8sRepoConfig config.KubernetesConfigsRepo, texts []string, txt string, caller Caller) (messages []string, request *pending.Request, err error) {
+	plan, err := command.PlanRelease(ctx, k8sRepoConfig, texts, txt)
+	if err != nil {
+		return nil, nil, err
+	}
+	run := func(ctx context.Context) ([]string, error) {
+		return command.Release(ctx, clusters, k8sRepoConfig, texts, txt, "+"+caller.Name)
+	}
+	if plan.DryRun {
+		messages, err = run(ctx)
+		return messages, nil, err
+	}
+
+	fields := []pending.Field{{Name: "Repo", Value: plan.Repo}}
+	if len(plan.Projects) != 0 {
+		fields = append(fields, pending.Field{Name: "Project", Value: strings.Join(plan.Projects, ", ")})
+	}
+	for _, t := range plan.Tags {
+		name := "Image tag"
+		if t.Image != "" {
+			name += " of " + t.Image
+		}
+		if len(plan.Projects) > 1 {
+			name += " in " + t.Project
+		}
+		fields = append(fields, pending.Field{Name: name, Value: fmt.Sprintf("%s → %s", t.DeployedTag, t.ImageTag)})
+	}
+
+	return hold(requests, pending.Request{
+		Title:     fmt.Sprintf("Release %s to prod?", plan.Repo),
+		Fields:    fields,
+		Repo:      plan.Repo,
+		Stage:     "prod",
+		TwoPerson: requests.TwoPerson(),
+		Run:       run,
+	}, caller)
+}
+
+// scale holds the scale of prod until it's approved if two persons are required
+func scale(ctx context.Context, requests *pending.Store, k8sRepoConfig config.KubernetesConfigsRepo, texts []string, txt string, caller Caller) (messages []string, request *pending.Request, err error) {
+	run := func(ctx context.Context) ([]string, error) {
+		return command.Scale(ctx, k8sRepoConfig, texts, txt, "+"+caller.Name)
+	}
+	if !requests.TwoPerson() {
+		messages, err = run(ctx)
+		return messages, nil, err
+	}
+
+	plan, err := command.PlanScale(ctx, k8sRepoConfig, texts, txt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan.Stage != "prod" || plan.DryRun {
+		messages, err = run(ctx)
+		return messages, nil, err
+	}
+
+	fields := []pending.Field{{Name: "Service", Value: plan.Service}}
+	if plan.MaxReplicas != 0 {
+		fields = append(fields, pending.Field{Name: "maxReplicas", Value: fmt.Sprintf("%d → %d", plan.DeployedMaxReplicas, plan.MaxReplicas)})
+	}
+	if plan.MinReplicas != 0 {
+		fields = append(fields, pending.Field{Name: "minReplicas", Value: fmt.Sprintf("%d → %d", plan.DeployedMinReplicas, plan.MinReplicas)})
+	}
+
+	return hold(requests, pending.Request{
+		Title:     fmt.Sprintf("Scale %s in prod?", plan.Service),
+		Fields:    fields,
+		Repo:      plan.Repo,
+		Stage:     plan.Stage,
+		TwoPerson: true,
+		Run:       run,
+	}, caller)
+}
+
+// promote holds the promotion to prod until it's confirmed, unless it's a dry run which changes nothing. It's confirmed by its caller or an approver even if two persons are required, which is for releases and scales
+func promote(ctx context.Context, requests *pending.Store, k8sRepoConfig config.KubernetesConfigsRepo, texts []string, txt string, caller Caller) (messages []string, request *pending.Request, err error) {
+	plan, err := command.PlanPromote(k8sRepoConfig, texts)
+	if err != nil {
+		return nil, nil, err
+	}
+	run := func(ctx context.Context) ([]string, error) {
+		return command.Promote(ctx, k8sRepoConfig, texts, txt, "+"+caller.Name)
+	}
+	if plan.To != "prod" || plan.DryRun {
+		messages, err = run(ctx)
+		return messages, nil, err
+	}
+
+	fields := []pending.Field{{Name: "Repo", Value: plan.Repo}}
+	if plan.Project != "" {
+		fields = append(fields, pending.Field{Name: "Project", Value: plan.Project})
+	}
+	if plan.Image != "" {
+		fields = append(fields, pending.Field{Name: "Image", Value: plan.Image})
+	}
+	fields = append(fields, pending.Field{Name: "From", Value: plan.From})
+
+	return hold(requests, pending.Request{
+		Title:  fmt.Sprintf("Promote %s from %s to prod?", plan.Repo, plan.From),
+		Fields: fields,
+		Repo:   plan.Repo,
+		Stage:  plan.To,
+		Run:    run,
+	}, caller)
+}
+
+// rollback holds the rollback of prod until it's confirmed, unless it's a dry run which changes nothing. Like a promotion, it never requires two persons
+func rollback(ctx context.Context, requests *pending.Store, k8sRepoConfig config.KubernetesConfigsRepo, texts []string, txt string, caller Caller) (messages []string, request *pending.Request, err error) {
+	plan, err := command.PlanRollback(k8sRepoConfig, texts)
+	if err != nil {
+		return nil, nil, err
+	}
+	run := func(ctx context.Context) ([]string, error) {
+		return command.Rollback(ctx, k8sRepoConfig, texts, txt, "+"+caller.Name)
+	}
+	if plan.Stage != "prod" || plan.DryRun {
+		messages, err = run(ctx)
+		return messages, nil, err
+	}
+
+	fields := []pending.Field{{Name: "Repo", Value: plan.Repo}}
+	if plan.Project != "" {
+		fields = append(fields, pending.Field{Name: "Project", Value: plan.Project})
+	}
+	if plan.Image != "" {
+		fields = append(fields, pending.Field{Name: "Image", Value: plan.Image})
+	}
+	to := plan.To
+	if to == "" {
+		to = "1"
+	}
+	fields = append(fields, pending.Field{Name: "To", Value: to})
+
+	return hold(requests, pending.Request{
+		Title:  fmt.Sprintf("Roll back %s in prod?", plan.Repo),
+		Fields: fields,
+		Repo:   plan.Repo,
+		Stage:  plan.Stage,
+		Run:    run,
+	}, caller)
+}
+
+func hold(requests *pending.Store, r pending.Request, caller Caller) (messages []string, request *pending.Request, err error) {
+	r.CallerID, r.CallerName, r.ChannelID = caller.ID, caller.Name, caller.ChannelID
+	r, err = requests.Add(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, &r, nil
+}
+
+// Approve carries out the request if the approver is allowed to by the store and the policy. The approver is recorded in the commit if it's not the caller, and the approval is recorded to the audit sink if there is one
+func Approve(ctx context.Context, requests *pending.Store, policy config.Policy, sink audit.Sink, id string, approver Caller) (request pending.Request, messages []string, err error) {
+	trace := &audit.Trace{}
+	start := time.Now()
+	defer func() {
+		record(sink, audit.Entry{
+			UserID:    approver.ID,
+			User:      approver.Name,
+			ChannelID: approver.ChannelID,
+			Text:      "approve " + id,
+			Command:   "approve",
+			Args:      []string{id},
+			Repo:      request.Repo,
+			Stage:     request.Stage,
+		}, trace, start, false, err)
+	}()
+	return approve(audit.WithTrace(ctx, trace), requests, policy, id, approver)
+}
+
+func approve(ctx context.Context, requests *pending.Store, policy config.Policy, id string, approver Caller) (request pending.Request, messages []string, err error) {
+	request, err = requests.Get(id)
+	if err != nil {
+		return request, nil, err
+	}
+	err = authorize(policy, config.Action{
+		UserID:    approver.ID,
+		ChannelID: approver.ChannelID,
+		Verb:      "approve",
+		Repo:      request.Repo,
+		Stage:     request.Stage,
+	}, approver, "approve "+id)
+	if err != nil {
+		audit.TraceFrom(ctx).Deny()
+		return request, nil, err
+	}
+
+	request, err = requests.Approve(id, approver.ID)
+	if err != nil {
+		return request, nil, err
+	}
+	if approver.ID != request.CallerID {
+		ctx = context.WithValue(ctx, mjcontext.Approver, "+"+approver.Name)
+	}
+	// the outcome of the approved command is for its caller, who may not be around the approval
+	if followUp, ok := ctx.Value(mjcontext.FollowUp).(command.FollowUp); ok {
+		followUp.Notify(request.CallerID)
+	}
+	messages, err = request.Run(ctx)
+	return request, messages, err
+}
+
+// formatRequests lists the requests waiting for approval
+func formatRequests(requests []pending.Request) []string {
+	if len(requests) == 0 {
+		return []string{"no request is waiting for approval"}
+	}
+	var messages []string
+	for i, r := range requests {
+		var fields []string
+		for _, f := range r.Fields {
+			fields = append(fields, fmt.Sprintf("%s: %s", f.Name, f.Value))
+		}
+		messages = append(messages, fmt.Sprintf("%d. `%s` %s requested by %s, expires at %s", i+1, r.ID, r.Title, r.CallerName, r.ExpireAt.Format("15:04:05")))
+		messages = append(messages, "   "+strings.Join(fields, ", "))
+	}
+	return messages
+}
@@ -0,0 +1,95 @@
+package slashcommand
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mirror-media/major-tom-go/v2/command"
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/sirupsen/logrus"
+)
+
+// verbs are the commands subject to the policy. approve is authorized with the request when it's approved
+var verbs = map[string]bool{
+	"list":      true,
+	"info":      true,
+	"reconcile": true,
+	"deploy":    true,
+	"release":   true,
+	"scale":     true,
+	"rollback":  true,
+	"promote":   true,
+	"approvals": true,
+	"audit":     true,
+	"freeze":    true,
+	"unfreeze":  true,
+}
+
+// escapes are the arguments bypassing a safety check, which are authorized as the verbs of their names on the same target as the command
+var escapes = map[string]bool{
+	// override makes a change in spite of the freezes
+	"override": true,
+	// force releases a tag which was never on staging
+	"force": true,
+}
+
+// authorizeCommand resolves the target of the command and checks it against the policy. The escapes are checked as their verbs on the same target
+func authorizeCommand(policy config.Policy, k8sRepoConfig config.KubernetesConfigsRepo, verb string, texts []string, caller Caller, txt string) error {
+	if !verbs[verb] {
+		return nil
+	}
+	action := config.Action{
+		UserID:    caller.ID,
+		ChannelID: caller.ChannelID,
+		Verb:      verb,
+	}
+	action.Repo, action.Stage = command.Target(k8sRepoConfig, verb, texts)
+	err := authorize(policy, action, caller, txt)
+	if err != nil {
+		return err
+	}
+	for _, escape := range enabledEscapes(texts) {
+		action.Verb = escape
+		err = authorize(policy, action, caller, txt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enabledEscapes returns the escapes turned on in texts. The values are parsed as the commands do, so override=1 or override=TRUE is an override as well
+func enabledEscapes(texts []string) []string {
+	var enabled []string
+	for _, text := range texts {
+		pair := strings.SplitN(text, "=", 2)
+		if len(pair) != 2 || !escapes[pair[0]] {
+			continue
+		}
+		if isEnabled, _ := strconv.ParseBool(pair[1]); isEnabled {
+			enabled = append(enabled, pair[0])
+		}
+	}
+	return enabled
+}
+
+// authorize checks the action against the policy and logs the decision for audit
+func authorize(policy config.Policy, action config.Action, caller Caller, txt string) error {
+	err := policy.Authorize(action)
+	entry := logrus.WithFields(logrus.Fields{
+		"audit":   true,
+		"user":    caller.Name,
+		"userID":  action.UserID,
+		"channel": action.ChannelID,
+		"verb":    action.Verb,
+		"repo":    action.Repo,
+		"stage":   action.Stage,
+		"command": txt,
+	})
+	if err != nil {
+		entry.Warn("command is denied by the policy")
+	} else {
+		entry.Info("command is allowed by the policy")
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package slashcommand
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/internal/test"
+)
+
+func Test_authorizeCommand(t *testing.T) {
+	policy := config.Policy{
+		Rules: []config.Rule{
+			{Users: []string{"U-dev"}, Verbs: []string{"deploy"}},
+			{Users: []string{"U-sre"}, Verbs: []string{"deploy", "override"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		userID  string
+		txt     string
+		wantErr bool
+	}{
+		{
+			name:   "deploy",
+			userID: "U-dev",
+			txt:    "deploy openwarehouse env=dev image-tag=dev_1bac23",
+		},
+		{
+			name:   "override=false is no override",
+			userID: "U-dev",
+			txt:    "deploy openwarehouse env=dev image-tag=dev_1bac23 override=false",
+		},
+		{
+			name:    "override=true without the override verb",
+			userID:  "U-dev",
+			txt:     "deploy openwarehouse env=dev image-tag=dev_1bac23 override=true",
+			wantErr: true,
+		},
+		{
+			name:    "override=1 without the override verb",
+			userID:  "U-dev",
+			txt:     "deploy openwarehouse env=dev image-tag=dev_1bac23 override=1",
+			wantErr: true,
+		},
+		{
+			name:    "override=TRUE without the override verb",
+			userID:  "U-dev",
+			txt:     "deploy openwarehouse env=dev image-tag=dev_1bac23 override=TRUE",
+			wantErr: true,
+		},
+		{
+			name:   "override=1 with the override verb",
+			userID: "U-sre",
+			txt:    "deploy openwarehouse env=dev image-tag=dev_1bac23 override=1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			texts := strings.Fields(tt.txt)
+			caller := Caller{ID: tt.userID, Name: tt.userID}
+			err := authorizeCommand(policy, test.K8sRepo, texts[0], texts[1:], caller, tt.txt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("authorizeCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
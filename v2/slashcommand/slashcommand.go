@@ -4,9 +4,13 @@ package slashcommand
 import (
 	"context"
 	"strings"
+	"time"
 
+	"github.com/mirror-media/major-tom-go/v2/audit"
 	"github.com/mirror-media/major-tom-go/v2/command"
 	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/k8sop"
+	"github.com/mirror-media/major-tom-go/v2/pending"
 
 	"github.com/pkg/errors"
 )
@@ -19,28 +23,78 @@ type CMD struct {
 	Text    string
 }
 
-// Run perform operation per cmd and txt. ctx is expected to have a response channel
-func Run(ctx context.Context /*clusterConfigs config.K8S,*/, k8sRepoConfig config.KubernetesConfigsRepo, slashcmd, txt, caller string) (messages []string, err error) {
+// Caller is the slack user running the command and the channel it's run in
+type Caller struct {
+	ID        string
+	Name      string
+	ChannelID string
+}
+
+// Run perform operation per cmd and txt if the policy allows the caller to, and records it to the audit sink if there is one. ctx is expected to have a response channel. A prod change is not run but returned as a request to be answered
+func Run(ctx context.Context, clusters *k8sop.ClusterResolver, requests *pending.Store, policy config.Policy, sink audit.Sink, k8sRepoConfig config.KubernetesConfigsRepo, slashcmd, txt string, caller Caller) (messages []string, request *pending.Request, err error) {
 	command.DeployWorker.Set(k8sRepoConfig.Git)
 	if slashcmd != ACCEPTED_SLASHCMD {
-		return []string{"call help"}, errors.Errorf("%s is not a supported slash command", slashcmd)
+		return []string{"call help"}, nil, errors.Errorf("%s is not a supported slash command", slashcmd)
 	}
+
+	trace := &audit.Trace{}
+	start := time.Now()
+	defer func() {
+		record(sink, newEntry(k8sRepoConfig, txt, caller), trace, start, request != nil, err)
+	}()
+	return run(audit.WithTrace(ctx, trace), clusters, requests, policy, sink, k8sRepoConfig, txt, caller)
+}
+
+// parseText splits the text into the command and its arguments. plan is a dry run of the mutating command following it
+func parseText(txt string) (cmd string, args []string) {
 	txtParts := strings.Split(txt, " ")
-	if len(txtParts) == 0 {
-		// TODO send help
-		return []string{"call help"}, nil
+	cmd = txtParts[0]
+	if cmd == "plan" && len(txtParts) > 1 {
+		txtParts = append(txtParts[1:], "dry-run=true")
+		cmd = txtParts[0]
 	}
+	return cmd, txtParts[1:]
+}
 
-	cmd := txtParts[0]
+func run(ctx context.Context, clusters *k8sop.ClusterResolver, requests *pending.Store, policy config.Policy, sink audit.Sink, k8sRepoConfig config.KubernetesConfigsRepo, txt string, caller Caller) (messages []string, request *pending.Request, err error) {
+	cmd, args := parseText(txt)
+	// txtParts keeps the command in front of the arguments like the text
+	txtParts := append([]string{cmd}, args...)
+	if err = authorizeCommand(policy, k8sRepoConfig, cmd, txtParts[1:], caller, txt); err != nil {
+		audit.TraceFrom(ctx).Deny()
+		return nil, nil, err
+	}
 	switch cmd {
-	// case "list":
-	// 	messages, err = command.List(ctx, clusterConfigs, txtParts[1:])
-	// case "info":
-	// 	messages, err = command.Info(ctx, clusterConfigs, txtParts[1:])
+	case "list":
+		messages, err = command.List(ctx, k8sRepoConfig, txtParts[1:], txt)
+	case "info":
+		messages, err = command.Info(ctx, clusters, k8sRepoConfig, txtParts[1:], txt)
 	case "deploy":
-		messages, err = command.Deploy(ctx, k8sRepoConfig, txtParts[1:], txt, "+"+caller)
+		messages, err = command.Deploy(ctx, clusters, k8sRepoConfig, txtParts[1:], txt, "+"+caller.Name)
 	case "release":
-		messages, err = command.Release(ctx, k8sRepoConfig, txtParts[1:], txt, "+"+caller)
+		messages, request, err = release(ctx, requests, clusters, k8sRepoConfig, txtParts[1:], txt, caller)
+	case "reconcile":
+		messages, err = command.Reconcile(ctx, clusters, k8sRepoConfig, txtParts[1:], txt)
+	case "scale":
+		messages, request, err = scale(ctx, requests, k8sRepoConfig, txtParts[1:], txt, caller)
+	case "rollback":
+		messages, request, err = rollback(ctx, requests, k8sRepoConfig, txtParts[1:], txt, caller)
+	case "promote":
+		messages, request, err = promote(ctx, requests, k8sRepoConfig, txtParts[1:], txt, caller)
+	case "freeze":
+		messages, err = command.Freeze(txtParts[1:], "+"+caller.Name)
+	case "unfreeze":
+		messages, err = command.Unfreeze(txtParts[1:], "+"+caller.Name)
+	case "approvals":
+		messages = formatRequests(requests.List())
+	case "audit":
+		messages, err = queryAudit(sink, txtParts[1:])
+	case "approve":
+		if len(txtParts) != 2 {
+			return []string{"call help"}, nil, errors.New("approve expects the id of a request")
+		}
+		// the command is recorded by Run already
+		_, messages, err = approve(ctx, requests, policy, txtParts[1], caller)
 	default:
 		if isBowie(txtParts) {
 			messages = command.Bowie()
@@ -49,7 +103,7 @@ func Run(ctx context.Context /*clusterConfigs config.K8S,*/, k8sRepoConfig confi
 			err = errors.Errorf("command(%s) is not supported", cmd)
 		}
 	}
-	return messages, err
+	return messages, request, err
 }
 
 func isBowie(txtParts []string) bool {
@@ -1,5 +1,20 @@
 package slashcommand
 
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mirror-media/major-tom-go/v2/audit"
+	"github.com/mirror-media/major-tom-go/v2/command"
+	"github.com/mirror-media/major-tom-go/v2/config"
+	"github.com/mirror-media/major-tom-go/v2/internal/test"
+	"github.com/mirror-media/major-tom-go/v2/pending"
+	"github.com/pkg/errors"
+)
+
 // func TestRun(t *testing.T) {
 // 	command.DeployWorker.Init(test.K8sRepo.GitConfig)
 // 	type args struct {
@@ -66,3 +81,226 @@ package slashcommand
 // 		})
 // 	}
 // }
+
+func Test_formatRequests(t *testing.T) {
+	expireAt := time.Date(2021, 8, 1, 12, 5, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		requests []pending.Request
+		want     []string
+	}{
+		{
+			name: "no request",
+			want: []string{"no request is waiting for approval"},
+		},
+		{
+			name: "requests with fields",
+			requests: []pending.Request{
+				{
+					ID:         "0a1b2c3d",
+					Title:      "Release openwarehouse to prod?",
+					Fields:     []pending.Field{{Name: "Repo", Value: "openwarehouse"}, {Name: "Image tag", Value: "a → b"}},
+					CallerName: "alice",
+					ExpireAt:   expireAt,
+				},
+				{
+					ID:         "4e5f6a7b",
+					Title:      "Scale openwarehouse-tv-cms in prod?",
+					Fields:     []pending.Field{{Name: "maxReplicas", Value: "2 → 4"}},
+					CallerName: "bob",
+					ExpireAt:   expireAt,
+				},
+			},
+			want: []string{
+				"1. `0a1b2c3d` Release openwarehouse to prod? requested by alice, expires at 12:05:00",
+				"   Repo: openwarehouse, Image tag: a → b",
+				"2. `4e5f6a7b` Scale openwarehouse-tv-cms in prod? requested by bob, expires at 12:05:00",
+				"   maxReplicas: 2 → 4",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatRequests(tt.requests); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("formatRequests() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_holdProd(t *testing.T) {
+	caller := Caller{ID: "U1", Name: "alice", ChannelID: "C1"}
+	commands := map[string]func(context.Context, *pending.Store, []string, string) ([]string, *pending.Request, error){
+		"promote": func(ctx context.Context, requests *pending.Store, texts []string, txt string) ([]string, *pending.Request, error) {
+			return promote(ctx, requests, test.K8sRepo, texts, txt, caller)
+		},
+		"rollback": func(ctx context.Context, requests *pending.Store, texts []string, txt string) ([]string, *pending.Request, error) {
+			return rollback(ctx, requests, test.K8sRepo, texts, txt, caller)
+		},
+	}
+	tests := []struct {
+		name      string
+		txt       string
+		wantTitle string
+		wantStage string
+	}{
+		{
+			name:      "promote to prod",
+			txt:       "promote openwarehouse from=staging to=prod project=tv",
+			wantTitle: "Promote openwarehouse from staging to prod?",
+			wantStage: "prod",
+		},
+		{
+			name:      "rollback of prod",
+			txt:       "rollback mirror-tv-nuxt env=prod",
+			wantTitle: "Roll back mirror-tv-nuxt in prod?",
+			wantStage: "prod",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// two persons are required by prod releases and scales only
+			requests := pending.NewStore(time.Minute, nil, true)
+			texts := strings.Fields(tt.txt)
+			messages, request, err := commands[texts[0]](context.Background(), requests, texts[1:], tt.txt)
+			if err != nil {
+				t.Fatalf("%s error = %v", texts[0], err)
+			}
+			if request == nil || len(messages) != 0 {
+				t.Fatalf("%s = %v, %v, want a pending request", texts[0], messages, request)
+			}
+			if request.Title != tt.wantTitle || request.Stage != tt.wantStage || request.CallerID != caller.ID || request.TwoPerson {
+				t.Errorf("%s request = %+v, want %q in %s for its caller", texts[0], *request, tt.wantTitle, tt.wantStage)
+			}
+			if got := len(requests.List()); got != 1 {
+				t.Errorf("the store has %d requests, want 1", got)
+			}
+			if got := command.QueueDepth(); got != 0 {
+				t.Errorf("%d deployments are queued, want 0", got)
+			}
+		})
+	}
+}
+
+// recordedFollowUp keeps the user notified by the command
+type recordedFollowUp struct {
+	userID string
+}
+
+func (f *recordedFollowUp) Expect()                           {}
+func (f *recordedFollowUp) Notify(userID string)              { f.userID = userID }
+func (f *recordedFollowUp) Post(messages []string, err error) {}
+
+func Test_approve(t *testing.T) {
+	caller := Caller{ID: "U1", Name: "alice", ChannelID: "C1"}
+	tests := []struct {
+		name     string
+		approver Caller
+	}{
+		{
+			name:     "confirmed by the caller",
+			approver: caller,
+		},
+		{
+			name:     "approved by someone else",
+			approver: Caller{ID: "U2", Name: "bob", ChannelID: "C1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := pending.NewStore(time.Minute, []string{"U2"}, false)
+			_, request, err := hold(requests, pending.Request{
+				Title: "Release?",
+				Run: func(ctx context.Context) ([]string, error) {
+					return []string{"released"}, nil
+				},
+			}, caller)
+			if err != nil {
+				t.Fatal(err)
+			}
+			followUp := &recordedFollowUp{}
+			_, messages, err := approve(command.WithFollowUp(context.Background(), followUp), requests, config.Policy{}, request.ID, tt.approver)
+			if err != nil || !reflect.DeepEqual(messages, []string{"released"}) {
+				t.Fatalf("approve() = %v, %v, want the messages of the request", messages, err)
+			}
+			if followUp.userID != caller.ID {
+				t.Errorf("approve() notified %q, want the caller %q", followUp.userID, caller.ID)
+			}
+		})
+	}
+}
+
+// memorySink keeps the entries written in the test
+type memorySink []audit.Entry
+
+func (s *memorySink) Write(e audit.Entry) error {
+	*s = append(*s, e)
+	return nil
+}
+
+func Test_record(t *testing.T) {
+	caller := Caller{ID: "U1", Name: "alice", ChannelID: "C1"}
+	tests := []struct {
+		name        string
+		txt         string
+		isPending   bool
+		err         error
+		deny        bool
+		wantOutcome string
+		wantRepo    string
+		wantStage   string
+	}{
+		{
+			name:        "deploy",
+			txt:         "deploy openwarehouse env=dev image-tag=dev_1bac23",
+			wantOutcome: audit.OutcomeOK,
+			wantRepo:    "openwarehouse",
+			wantStage:   "dev",
+		},
+		{
+			name:        "release waiting for approval",
+			txt:         "release openwarehouse project=tv image-tag=prod_399440e",
+			isPending:   true,
+			wantOutcome: audit.OutcomePending,
+			wantRepo:    "openwarehouse",
+			wantStage:   "prod",
+		},
+		{
+			name:        "failed plan",
+			txt:         "plan rollback mirror-tv-nuxt env=staging",
+			err:         errors.New("there aren't enough changes to roll back"),
+			wantOutcome: audit.OutcomeError,
+			wantRepo:    "mirror-tv-nuxt",
+			wantStage:   "staging",
+		},
+		{
+			name:        "denied",
+			txt:         "scale openwarehouse-tv-cms env=prod maxReplicas=4",
+			err:         errors.New("permission denied"),
+			deny:        true,
+			wantOutcome: audit.OutcomeDenied,
+			wantRepo:    "openwarehouse",
+			wantStage:   "prod",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sink memorySink
+			trace := &audit.Trace{}
+			if tt.deny {
+				trace.Deny()
+			}
+			record(&sink, newEntry(test.K8sRepo, tt.txt, caller), trace, time.Now(), tt.isPending, tt.err)
+			if len(sink) != 1 {
+				t.Fatalf("record() wrote %d entries", len(sink))
+			}
+			e := sink[0]
+			if e.Outcome != tt.wantOutcome || e.Repo != tt.wantRepo || e.Stage != tt.wantStage || e.User != "alice" || e.Text != tt.txt {
+				t.Errorf("record() = %+v, want outcome %v in %v/%v", e, tt.wantOutcome, tt.wantRepo, tt.wantStage)
+			}
+			if (e.Error != "") != (tt.err != nil) {
+				t.Errorf("record() error = %v, want %v", e.Error, tt.err)
+			}
+		})
+	}
+}
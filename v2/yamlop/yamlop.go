@@ -0,0 +1,279 @@
+// Package yamlop edits YAML files in place. Only the edited scalars change, and the comments, the order of keys and the quoting of everything else are kept byte for byte
+package yamlop
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotFound is the cause of the errors for a path not found
+var ErrNotFound = errors.New("not found")
+
+// Document is a YAML document to be edited in place
+type Document struct {
+	src  []byte
+	root *yaml.Node
+}
+
+// Parse parses the first document in b
+func Parse(b []byte) (*Document, error) {
+	d := &Document{}
+	return d, d.reset(b)
+}
+
+func (d *Document) reset(b []byte) error {
+	var root yaml.Node
+	err := yaml.Unmarshal(b, &root)
+	if err != nil {
+		return err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return errors.New("document is empty")
+	}
+	d.src, d.root = b, root.Content[0]
+	return nil
+}
+
+// Bytes returns the edited document
+func (d *Document) Bytes() []byte {
+	return d.src
+}
+
+// Get returns the value of the scalar at path. path is dot separated keys and indexes of sequences like images.0.newTag
+func (d *Document) Get(path string) (string, error) {
+	n, err := d.find(path)
+	if err != nil {
+		return "", err
+	}
+	if n.Kind != yaml.ScalarNode {
+		return "", errors.Errorf("%s is not a scalar", path)
+	}
+	return n.Value, nil
+}
+
+// Len returns the number of the items of the sequence at path
+func (d *Document) Len(path string) (int, error) {
+	n, err := d.find(path)
+	if err != nil {
+		return 0, err
+	}
+	if n.Kind != yaml.SequenceNode {
+		return 0, errors.Errorf("%s is not a sequence", path)
+	}
+	return len(n.Content), nil
+}
+
+// Set replaces the scalar at path with value in the same quoting style, or adds the key to its block mapping if it doesn't exist. value is a string, a number or a bool
+func (d *Document) Set(path string, value interface{}) error {
+	var v yaml.Node
+	err := v.Encode(value)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("encoding value of %s has error", path))
+	}
+	if v.Kind != yaml.ScalarNode {
+		return errors.Errorf("value of %s is not a scalar", path)
+	}
+
+	n, err := d.find(path)
+	if errors.Cause(err) == ErrNotFound {
+		return d.add(path, &v)
+	} else if err != nil {
+		return err
+	}
+	if n.Kind != yaml.ScalarNode {
+		return errors.Errorf("%s is not a scalar", path)
+	}
+	if n.Anchor != "" || n.Style&(yaml.TaggedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return errors.Errorf("%s is a scalar not supported for editing", path)
+	}
+
+	start := d.offset(n.Line, n.Column)
+	end, err := d.scalarEnd(n, start)
+	if err != nil {
+		return errors.Wrap(err, path)
+	}
+	v.Style = n.Style
+	text, err := formatScalar(&v)
+	if err != nil {
+		return errors.Wrap(err, path)
+	}
+	return d.splice(start, end, text)
+}
+
+// find walks the path from the root
+func (d *Document) find(path string) (*yaml.Node, error) {
+	n := d.root
+	for _, key := range strings.Split(path, ".") {
+		next, err := child(n, key)
+		if err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+		n = next
+	}
+	return n, nil
+}
+
+func child(n *yaml.Node, key string) (*yaml.Node, error) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key {
+				return n.Content[i+1], nil
+			}
+		}
+	case yaml.SequenceNode:
+		i, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, errors.Errorf("%s is not an index of a sequence", key)
+		}
+		if i >= 0 && i < len(n.Content) {
+			return n.Content[i], nil
+		}
+	case yaml.AliasNode:
+		return nil, errors.New("alias is not supported")
+	default:
+		return nil, errors.Errorf("%s is under a scalar", key)
+	}
+	return nil, errors.Wrap(ErrNotFound, key)
+}
+
+// add appends the key to the block mapping of the parent path, after the lines of its last entry
+func (d *Document) add(path string, v *yaml.Node) error {
+	key := path
+	var parent *yaml.Node
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		var err error
+		key = path[i+1:]
+		parent, err = d.find(path[:i])
+		if err != nil {
+			return err
+		}
+	} else {
+		parent = d.root
+	}
+	if parent.Kind != yaml.MappingNode || parent.Style&yaml.FlowStyle != 0 || len(parent.Content) == 0 {
+		return errors.Errorf("%s can only be added to a non-empty block mapping", path)
+	}
+
+	text, err := formatScalar(v)
+	if err != nil {
+		return errors.Wrap(err, path)
+	}
+	lastKey, lastValue := parent.Content[len(parent.Content)-2], parent.Content[len(parent.Content)-1]
+	indent := lastKey.Column - 1
+	at := d.entryEnd(lastKey.Line, indent, lastValue.Kind == yaml.SequenceNode)
+
+	line := fmt.Sprintf("%s%s: %s\n", strings.Repeat(" ", indent), key, text)
+	if at > 0 && d.src[at-1] != '\n' {
+		line = "\n" + line
+	}
+	return d.splice(at, at, line)
+}
+
+// entryEnd returns the offset after the last line of the entry starting at line. The entry ends before the first line indented no more than the mapping, ignoring blank lines and comments. A sequence can be as indented as its key
+func (d *Document) entryEnd(line, indent int, isSequence bool) int {
+	end := d.offset(line+1, 1)
+	for pos := end; pos < len(d.src); {
+		next := bytes.IndexByte(d.src[pos:], '\n')
+		lineEnd := len(d.src)
+		if next >= 0 {
+			lineEnd = pos + next + 1
+		}
+		text := string(d.src[pos:lineEnd])
+		trimmed := strings.TrimLeft(text, " ")
+		content := strings.TrimSpace(trimmed)
+		if content != "" && !strings.HasPrefix(content, "#") {
+			lineIndent := len(text) - len(trimmed)
+			isItem := isSequence && lineIndent == indent && (content == "-" || strings.HasPrefix(content, "- "))
+			if lineIndent <= indent && !isItem {
+				break
+			}
+			end = lineEnd
+		}
+		pos = lineEnd
+	}
+	return end
+}
+
+// offset returns the byte offset of the line and the column in characters, both starting from 1
+func (d *Document) offset(line, column int) int {
+	pos := 0
+	for l := 1; l < line; l++ {
+		next := bytes.IndexByte(d.src[pos:], '\n')
+		if next < 0 {
+			return len(d.src)
+		}
+		pos += next + 1
+	}
+	for c := 1; c < column && pos < len(d.src); c++ {
+		_, size := utf8.DecodeRune(d.src[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// scalarEnd returns the offset after the scalar starting at start
+func (d *Document) scalarEnd(n *yaml.Node, start int) (int, error) {
+	src := d.src
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(src); i++ {
+			switch src[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, nil
+			}
+		}
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(src); i++ {
+			if src[i] != '\'' {
+				continue
+			}
+			if i+1 < len(src) && src[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	default:
+		end := start + len(n.Value)
+		if end <= len(src) && string(src[start:end]) == n.Value {
+			return end, nil
+		}
+		return 0, errors.New("multi-line plain scalar is not supported for editing")
+	}
+	return 0, errors.New("scalar is not closed")
+}
+
+// formatScalar renders the scalar in its style, or quoted if its value would be read as another type in plain style
+func formatScalar(v *yaml.Node) (string, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSuffix(string(b), "\n")
+	if strings.Contains(text, "\n") {
+		return "", errors.New("multi-line value is not supported")
+	}
+	return text, nil
+}
+
+// splice replaces src[start:end] with text and parses the result, so the nodes are in the right positions for the next edit
+func (d *Document) splice(start, end int, text string) error {
+	b := make([]byte, 0, len(d.src)-(end-start)+len(text))
+	b = append(b, d.src[:start]...)
+	b = append(b, text...)
+	b = append(b, d.src[end:]...)
+	err := d.reset(b)
+	if err != nil {
+		return errors.Wrap(err, "edited document is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package yamlop
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+type testEdit struct {
+	path  string
+	value interface{}
+}
+
+func TestDocument_Set(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		golden string
+		edits  []testEdit
+	}{
+		{
+			name:   "double quoted tag with a comment",
+			input:  "kustomization.yaml",
+			golden: "kustomization-newtag.golden",
+			edits:  []testEdit{{path: "images.0.newTag", value: "prod_399440e"}},
+		},
+		{
+			name:   "plain tag which would be a number",
+			input:  "kustomization.yaml",
+			golden: "kustomization-number.golden",
+			edits:  []testEdit{{path: "images.1.newTag", value: "1.10"}},
+		},
+		{
+			name:   "single quoted name",
+			input:  "kustomization.yaml",
+			golden: "kustomization-newname.golden",
+			edits:  []testEdit{{path: "images.0.newName", value: "gcr.io/mirrormedia-1470651750304/openwarehouse-cms"}},
+		},
+		{
+			name:   "replace and add replicas",
+			input:  "hpa.yaml",
+			golden: "hpa-replicas.golden",
+			edits: []testEdit{
+				{path: "spec.maxReplicas", value: 6},
+				{path: "spec.minReplicas", value: 2},
+			},
+		},
+		{
+			name:   "unicode keys and values",
+			input:  "unicode.yaml",
+			golden: "unicode.golden",
+			edits: []testEdit{
+				{path: "標籤", value: "v2"},
+				{path: "描述", value: "新的"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := ioutil.ReadFile(filepath.Join("testdata", tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := Parse(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range tt.edits {
+				if err := d.Set(e.path, e.value); err != nil {
+					t.Fatalf("Document.Set(%s) error = %v", e.path, err)
+				}
+			}
+
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := ioutil.WriteFile(golden, d.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(d.Bytes()); got != string(want) {
+				t.Errorf("Document.Bytes() = \n%s\nwant\n%s", got, want)
+			}
+
+			// the edited values can be read back
+			for _, e := range tt.edits {
+				if _, err := d.Get(e.path); err != nil {
+					t.Errorf("Document.Get(%s) error = %v", e.path, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDocument_SetUnsupported(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		path  string
+	}{
+		{
+			name:  "block scalar",
+			input: "description: |\n  multi\n  line\n",
+			path:  "description",
+		},
+		{
+			name:  "anchor",
+			input: "tag: &tag v1\nother: *tag\n",
+			path:  "tag",
+		},
+		{
+			name:  "add to a flow mapping",
+			input: "spec: {maxReplicas: 4}\n",
+			path:  "spec.minReplicas",
+		},
+		{
+			name:  "not a scalar",
+			input: "images:\n- name: cms\n",
+			path:  "images",
+		},
+		{
+			name:  "under a scalar",
+			input: "spec: none\n",
+			path:  "spec.maxReplicas",
+		},
+		{
+			name:  "index out of range is not added",
+			input: "images:\n- name: cms\n",
+			path:  "images.1.newTag",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Set(tt.path, "v2"); err == nil {
+				t.Errorf("Document.Set(%s) should fail", tt.path)
+			}
+			if got := string(d.Bytes()); got != tt.input {
+				t.Errorf("Document.Bytes() = %q, should be unchanged", got)
+			}
+		})
+	}
+}
+
+func TestDocument_Get(t *testing.T) {
+	d, err := Parse([]byte("spec:\n  maxReplicas: 4\nimages:\n- newTag: \"v1\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{"spec.maxReplicas": "4", "images.0.newTag": "v1"} {
+		got, err := d.Get(path)
+		if err != nil || got != want {
+			t.Errorf("Document.Get(%s) = %v, %v, want %v", path, got, err, want)
+		}
+	}
+	if _, err := d.Get("spec.minReplicas"); err == nil {
+		t.Errorf("Document.Get() should fail for a missing key")
+	}
+}
+
+func TestDocument_Len(t *testing.T) {
+	d, err := Parse([]byte("spec:\n  maxReplicas: 4\nimages:\n- name: cms\n- name: sidecar\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := d.Len("images"); err != nil || got != 2 {
+		t.Errorf("Document.Len(images) = %v, %v, want 2", got, err)
+	}
+	if _, err := d.Len("spec"); err == nil {
+		t.Errorf("Document.Len() should fail for a mapping")
+	}
+}